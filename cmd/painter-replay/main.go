@@ -0,0 +1,129 @@
+// Команда painter-replay відтворює журнал скриптів, записаний painter з параметром -journal-dir,
+// на програмній текстурі та зберігає останній кадр у PNG файл.
+//
+//	painter-replay -journal journal/default.journal -until 14:02 -out 1402.png
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/roman-mazur/architecture-lab-3/painter/lang"
+	"golang.org/x/exp/shiny/screen"
+)
+
+var (
+	journal  = flag.String("journal", "", "path to the journal file to replay")
+	out      = flag.String("out", "replay.png", "path of the PNG file for the last rendered frame")
+	realTime = flag.Bool("realtime", false, "replay with the original delays between scripts instead of as fast as possible")
+	until    = flag.String("until", "", "stop after the scripts executed at this time: RFC 3339 timestamp or time of day (15:04 or 15:04:05) on the date of the first entry")
+	stateDir = flag.String("state-dir", "", "directory with saved scenes for load commands; save commands write there too, so pass a copy")
+)
+
+// lastFrame запам'ятовує копію останнього кадру, переданого циклом подій.
+type lastFrame struct {
+	mu  sync.Mutex
+	img *image.RGBA
+}
+
+func (f *lastFrame) Update(t screen.Texture) {
+	if tx, ok := t.(*painter.ImageTexture); ok {
+		f.mu.Lock()
+		f.img = tx.Snapshot()
+		f.mu.Unlock()
+	}
+}
+
+func main() {
+	flag.Parse()
+	if *journal == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*journal)
+	if err != nil {
+		log.Fatal(err)
+	}
+	entries, err := lang.ReadJournal(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("Cannot read journal: %s", err)
+	}
+
+	opts := lang.ReplayOptions{RealTime: *realTime}
+	if *until != "" {
+		if len(entries) == 0 {
+			log.Fatal("Journal is empty")
+		}
+		if opts.Until, err = parseUntil(*until, entries[0].Time); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var (
+		frame lastFrame
+		loop  = painter.Loop{Receiver: &frame}
+	)
+	loop.Start(ctx, painter.HeadlessScreen{})
+	n, err := lang.Replay(ctx, entries, &lang.Parser{StateDir: *stateDir, CanBlend: loop.CanBlend}, &loop, opts)
+	loop.StopAndWait()
+	if err != nil {
+		log.Printf("Replay interrupted: %s", err)
+	}
+
+	frame.mu.Lock()
+	img := frame.img
+	frame.mu.Unlock()
+	if img == nil {
+		log.Fatalf("No frame was rendered by %d replayed scripts", n)
+	}
+	if err := savePNG(*out, img); err != nil {
+		log.Fatalf("Cannot save frame: %s", err)
+	}
+	log.Printf("Replayed %d of %d scripts, last frame saved to %s", n, len(entries), *out)
+}
+
+// parseUntil розбирає момент зупинки відтворення. Час доби відраховується від дати першого запису журналу
+// у його часовому поясі.
+func parseUntil(s string, first time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			y, m, d := first.Date()
+			// Кінець вказаної хвилини чи секунди, щоб "14:02" охоплювало все, що виконано о 14:02.
+			precision := time.Second
+			if layout == "15:04" {
+				precision = time.Minute
+			}
+			return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, first.Location()).Add(precision - 1), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid -until value: %q", s)
+}
+
+func savePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -1,33 +1,108 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/roman-mazur/architecture-lab-3/painter"
-	"github.com/roman-mazur/architecture-lab-3/painter/lang"
+	"github.com/roman-mazur/architecture-lab-3/painter/canvas"
 	"github.com/roman-mazur/architecture-lab-3/ui"
+	"golang.org/x/exp/shiny/screen"
+)
+
+var (
+	canvases   = flag.String("canvases", "", "comma-separated names of canvases to create at startup")
+	show       = flag.String("show", canvas.DefaultName, "name of the canvas displayed in the window")
+	headless   = flag.Bool("headless", false, "run without a window; canvases are available only over HTTP, e.g. via /stream")
+	stateDir   = flag.String("state-dir", "", "directory for scenes saved with save/load commands; canvases are also saved there on exit and restored at startup")
+	load       = flag.String("load", "", "name of a saved scene to load into the default canvas at startup (requires -state-dir)")
+	journalDir = flag.String("journal-dir", "", "directory for per-canvas journals of executed scripts, see painter-replay")
+	fps        = flag.Int("fps", painter.DefaultFrameRate, "frame rate of animations started with the animate command")
+	maxFPS     = flag.Int("max-fps", 60, "maximum rate of frames presented by each canvas; updates arriving faster are merged (0 disables the limit)")
+	queueCap   = flag.Int("queue-capacity", 1024, "maximum number of operations waiting in each canvas queue (0 means unbounded)")
+	overflow   = flag.String("queue-policy", "reject", "what to do when a canvas queue is full: block, drop-oldest or reject (HTTP 429)")
 )
 
 func main() {
+	flag.Parse()
+
 	var (
 		pv ui.Visualizer // Візуалізатор створює вікно та малює у ньому.
-
-		// Потрібні для частини 2.
-		opLoop painter.Loop // Цикл обробки команд.
-		parser lang.Parser  // Парсер команд.
 	)
 
+	policy, err := painter.ParseOverflowPolicy(*overflow)
+	if err != nil {
+		log.Fatalf("Invalid -queue-policy: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Реєстр полотен: кожне має власний парсер команд та цикл обробки команд.
+	registry := canvas.NewRegistry(ctx, canvas.Config{
+		StateDir:      *stateDir,
+		JournalDir:    *journalDir,
+		FrameRate:     *fps,
+		MaxFPS:        *maxFPS,
+		QueueCapacity: *queueCap,
+		Overflow:      policy,
+	})
+	if err := registry.Restore(); err != nil {
+		log.Printf("Cannot restore canvases: %s", err)
+	}
+	if *load != "" {
+		if err := registry.Load(canvas.DefaultName, *load); err != nil {
+			log.Fatalf("Cannot load scene %q: %s", *load, err)
+		}
+	}
+	for _, name := range strings.Split(*canvases, ",") {
+		if name = strings.TrimSpace(name); name == "" || name == canvas.DefaultName {
+			continue
+		}
+		if _, err := registry.Create(name); err != nil {
+			log.Fatalf("Cannot create canvas %q: %s", name, err)
+		}
+	}
+	if err := registry.Show(*show); err != nil {
+		log.Fatalf("Cannot show canvas %q: %s", *show, err)
+	}
+
 	//pv.Debug = true
 	pv.Title = "Simple painter"
 
-	pv.OnScreenReady = opLoop.Start
-	opLoop.Receiver = &pv
+	pv.OnScreenReady = func(s screen.Screen) { registry.SetDisplay(canvas.NewPresenter(s, &pv)) }
 
+	server := &http.Server{Addr: "localhost:17000", Handler: registry.Handler()}
 	go func() {
-		http.Handle("/", lang.HttpHandler(&opLoop, &parser))
-		_ = http.ListenAndServe("localhost:17000", nil)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP server failed: %s", err)
+		}
 	}()
 
-	pv.Main()
-	opLoop.StopAndWait()
+	if *headless {
+		// Без вікна сервер працює, доки процес не отримає сигнал завершення.
+		sigCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		<-sigCtx.Done()
+		stop()
+	} else {
+		pv.Main()
+	}
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server shutdown: %s", err)
+	}
+	if err := registry.Autosave(); err != nil {
+		log.Printf("Cannot save canvases: %s", err)
+	}
+	registry.Close()
 }
@@ -3,6 +3,7 @@ module github.com/roman-mazur/architecture-lab-3
 go 1.24
 
 require (
+	github.com/gorilla/websocket v1.5.3
 	github.com/stretchr/testify v1.10.0
 	golang.org/x/exp/shiny v0.0.0-20250305212735-054e65f0b394
 	golang.org/x/image v0.25.0
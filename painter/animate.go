@@ -0,0 +1,133 @@
+package painter
+
+import (
+	"errors"
+	"maps"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// DefaultFrameRate — частота кадрів анімації, якщо Loop.FrameRate не задано.
+const DefaultFrameRate = 30
+
+// Animator обчислює кадри анімацій, які генерує Loop.
+type Animator interface {
+	// Frame повертає операцію, що малює кадр на момент t (або nil, якщо малювати нічого),
+	// та false, якщо після цього кадру анімації завершились. Викликається у горутині циклу подій.
+	Frame(t time.Time) (op Operation, active bool)
+}
+
+// animators — аніматори циклу подій та стан горутини, що генерує їхні кадри.
+type animators struct {
+	mu      sync.Mutex
+	active  map[Animator]uint64 // Аніматор і кількість викликів Animate для нього.
+	ticking bool
+}
+
+// Animate доручає циклу подій генерувати кадри аніматора a з частотою FrameRate, доки a має активні анімації.
+// Повторний виклик для того самого аніматора (наприклад, після додавання нової анімації) безпечний.
+// Цикл повинен бути запущений методом Start.
+func (l *Loop) Animate(a Animator) {
+	l.anim.mu.Lock()
+	defer l.anim.mu.Unlock()
+
+	if l.anim.active == nil {
+		l.anim.active = make(map[Animator]uint64)
+	}
+	l.anim.active[a]++
+	if !l.anim.ticking && l.stopped != nil {
+		l.anim.ticking = true
+		go l.tick()
+	}
+}
+
+// tick з частотою FrameRate ставить у чергу кадр аніматорів, доки вони активні або доки цикл подій не зупинено.
+// Поки попередній кадр не виконано, новий не додається, тож повільне малювання пропускає кадри, а не переповнює чергу.
+func (l *Loop) tick() {
+	rate := l.FrameRate
+	if rate <= 0 {
+		rate = DefaultFrameRate
+	}
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+
+	var done chan struct{} // Не nil, поки кадр чекає у черзі.
+	for {
+		select {
+		case now := <-ticker.C:
+			if done != nil {
+				l.stats.dropped.Add(1)
+				continue
+			}
+			l.anim.mu.Lock()
+			current := maps.Clone(l.anim.active)
+			l.anim.mu.Unlock()
+
+			done = make(chan struct{})
+			err := l.Post(&frameOp{loop: l, time: now, animators: current, done: done})
+			if errors.Is(err, ErrQueueFull) {
+				done = nil
+				l.stats.dropped.Add(1)
+				continue
+			}
+			if err != nil {
+				l.stopTicking()
+				return
+			}
+		case <-done:
+			done = nil
+			l.anim.mu.Lock()
+			if len(l.anim.active) == 0 {
+				l.anim.ticking = false
+				l.anim.mu.Unlock()
+				return
+			}
+			l.anim.mu.Unlock()
+		case <-l.stopped:
+			l.stopTicking()
+			return
+		}
+	}
+}
+
+func (l *Loop) stopTicking() {
+	l.anim.mu.Lock()
+	defer l.anim.mu.Unlock()
+	l.anim.ticking = false
+	l.anim.active = nil
+}
+
+// frameOp малює кадр аніматорів на момент time. Кадр обчислюється під час виконання операції, тому він враховує
+// всі операції, виконані раніше, і не може перезаписати новіший стан застарілим.
+type frameOp struct {
+	loop      *Loop
+	time      time.Time
+	animators map[Animator]uint64 // Аніматори та їхні лічильники викликів Animate на момент створення кадру.
+	done      chan struct{}
+}
+
+func (op *frameOp) Do(t screen.Texture) bool {
+	return op.DoEach(func(o Operation) bool { return o.Do(t) })
+}
+
+// DoEach реалізує Composite: операції кожного аніматора передаються у do.
+func (op *frameOp) DoEach(do func(Operation) bool) (ready bool) {
+	defer close(op.done)
+	for a, gen := range op.animators {
+		frame, active := a.Frame(op.time)
+		if frame != nil {
+			ready = do(frame) || ready
+		}
+		if !active {
+			op.loop.anim.mu.Lock()
+			// Аніматор, для якого Animate викликали вже після створення кадру, залишається активним.
+			if op.loop.anim.active[a] == gen {
+				delete(op.loop.anim.active, a)
+			}
+			op.loop.anim.mu.Unlock()
+		}
+	}
+	return ready
+}
@@ -0,0 +1,85 @@
+package painter
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingAnimator малює frames кадрів і запам'ятовує їхній час.
+type countingAnimator struct {
+	mu     sync.Mutex
+	frames int
+	times  []time.Time
+}
+
+func (a *countingAnimator) Frame(t time.Time) (Operation, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.times = append(a.times, t)
+	return UpdateOp, len(a.times) < a.frames
+}
+
+func (a *countingAnimator) count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.times)
+}
+
+func (l *Loop) isTicking() bool {
+	l.anim.mu.Lock()
+	defer l.anim.mu.Unlock()
+	return l.anim.ticking
+}
+
+func TestAnimateRunsAnimatorsInParallel(t *testing.T) {
+	loop, _, receiverMock, _ := newLoopWithMocks(t)
+	loop.FrameRate = 100
+
+	short, long := &countingAnimator{frames: 3}, &countingAnimator{frames: 6}
+	loop.Animate(short)
+	loop.Animate(long)
+
+	require.Eventually(t, func() bool { return !loop.isTicking() }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 3, short.count())
+	assert.Equal(t, 6, long.count())
+	assert.Equal(t, short.times, long.times[:3], "animators share frames")
+	for i := 1; i < len(long.times); i++ {
+		assert.True(t, long.times[i].After(long.times[i-1]))
+	}
+	receiverMock.AssertNumberOfCalls(t, "Update", 6)
+	latency := loop.OpLatency()
+	assert.Equal(t, uint64(9), latency["updateOp"].Count, "animation frames are counted by their operations")
+	assert.NotContains(t, latency, "frameOp")
+}
+
+func TestAnimateRestartsAfterFinish(t *testing.T) {
+	loop, _, _, _ := newLoopWithMocks(t)
+	loop.FrameRate = 100
+
+	a := &countingAnimator{frames: 2}
+	loop.Animate(a)
+	require.Eventually(t, func() bool { return !loop.isTicking() }, time.Second, 5*time.Millisecond)
+
+	a.mu.Lock()
+	a.frames = 4
+	a.mu.Unlock()
+	loop.Animate(a)
+	require.Eventually(t, func() bool { return !loop.isTicking() }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 4, a.count())
+}
+
+func TestAnimateStopsWithLoop(t *testing.T) {
+	loop, _, _, _ := newLoopWithMocks(t)
+	loop.FrameRate = 100
+
+	a := &countingAnimator{frames: 1 << 30}
+	loop.Animate(a)
+	require.Eventually(t, func() bool { return a.count() > 0 }, time.Second, 5*time.Millisecond)
+
+	loop.StopAndWait()
+	require.Eventually(t, func() bool { return !loop.isTicking() }, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,139 @@
+package painter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// BlendMode визначає, як колір операції поєднується з кольором, що вже є на текстурі.
+// Нульове значення BlendOver накладає колір поверх наявного з урахуванням прозорості.
+type BlendMode int
+
+const (
+	BlendOver     BlendMode = iota // Накладання з урахуванням альфа-каналу (draw.Over).
+	BlendSrc                       // Заміна кольору без змішування (draw.Src).
+	BlendMultiply                  // Множення кольорів: результат завжди темніший.
+	BlendScreen                    // Інверсія добутку інвертованих кольорів: результат завжди світліший.
+	BlendXor                       // Побітове виключне АБО колірних каналів.
+)
+
+var blendModeNames = [...]string{
+	BlendOver:     "over",
+	BlendSrc:      "src",
+	BlendMultiply: "multiply",
+	BlendScreen:   "screen",
+	BlendXor:      "xor",
+}
+
+func (m BlendMode) String() string {
+	if m >= 0 && int(m) < len(blendModeNames) {
+		return blendModeNames[m]
+	}
+	return fmt.Sprintf("BlendMode(%d)", int(m))
+}
+
+// ParseBlendMode повертає режим змішування за його назвою.
+func ParseBlendMode(name string) (BlendMode, error) {
+	for m, n := range blendModeNames {
+		if n == name {
+			return BlendMode(m), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown blend mode: %s", name)
+}
+
+// Blender реалізують текстури, які вміють змішувати кольори у режимах, що не підтримуються draw.Op.
+type Blender interface {
+	Blend(dr image.Rectangle, src color.Color, mode BlendMode)
+}
+
+// WithOpacity повертає колір c, прозорість якого додатково помножено на opacity (від 0 до 1).
+func WithOpacity(c color.Color, opacity float64) color.Color {
+	opacity = math.Max(0, math.Min(1, opacity))
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	n.A = uint8(float64(n.A)*opacity + 0.5)
+	return n
+}
+
+// CanBlend повідомляє, чи можна малювати на текстурі t у режимі mode: режими, яких немає у draw.Op,
+// потребують текстури з інтерфейсом Blender.
+func CanBlend(t screen.Texture, mode BlendMode) bool {
+	if mode == BlendOver || mode == BlendSrc {
+		return true
+	}
+	_, ok := t.(Blender)
+	return ok
+}
+
+// fill зафарбовує прямокутник r на текстурі кольором c у режимі mode.
+// Якщо текстура не підтримує режим (див. CanBlend), використовується BlendOver. Щоб скрипт не малював
+// на екрані інакше, ніж без вікна, lang.Parser відхиляє такі режими ще до виконання (див. Loop.CanBlend).
+func fill(t screen.Texture, r image.Rectangle, c color.Color, mode BlendMode) {
+	switch mode {
+	case BlendSrc:
+		t.Fill(r, c, draw.Src)
+	case BlendOver:
+		t.Fill(r, c, draw.Over)
+	default:
+		if b, ok := t.(Blender); ok {
+			b.Blend(r, c, mode)
+			return
+		}
+		t.Fill(r, c, draw.Over)
+	}
+}
+
+// Blend змішує колір src з вмістом текстури у прямокутнику dr за правилами режиму mode.
+func (t *ImageTexture) Blend(dr image.Rectangle, src color.Color, mode BlendMode) {
+	switch mode {
+	case BlendSrc:
+		t.Fill(dr, src, draw.Src)
+		return
+	case BlendOver:
+		t.Fill(dr, src, draw.Over)
+		return
+	}
+
+	s := color.NRGBAModel.Convert(src).(color.NRGBA)
+	sa := float64(s.A) / 255
+	sc := [3]float64{float64(s.R) / 255, float64(s.G) / 255, float64(s.B) / 255}
+	dr = dr.Intersect(t.img.Rect)
+
+	for y := dr.Min.Y; y < dr.Max.Y; y++ {
+		for x := dr.Min.X; x < dr.Max.X; x++ {
+			i := t.img.PixOffset(x, y)
+			px := t.img.Pix[i : i+4 : i+4]
+			da := float64(px[3]) / 255
+
+			for ch := 0; ch < 3; ch++ {
+				// Пікселі image.RGBA зберігаються помноженими на альфа-канал.
+				dc := 0.0
+				if da > 0 {
+					dc = float64(px[ch]) / 255 / da
+				}
+				mixed := (1-da)*sc[ch] + da*blendChannel(mode, dc, sc[ch])
+				px[ch] = uint8(math.Round((sa*mixed + (1-sa)*da*dc) * 255))
+			}
+			px[3] = uint8(math.Round((sa + da*(1-sa)) * 255))
+		}
+	}
+}
+
+// blendChannel обчислює результат змішування одного колірного каналу фону d та джерела s (значення від 0 до 1).
+func blendChannel(mode BlendMode, d, s float64) float64 {
+	switch mode {
+	case BlendMultiply:
+		return d * s
+	case BlendScreen:
+		return d + s - d*s
+	case BlendXor:
+		return float64(uint8(math.Round(d*255))^uint8(math.Round(s*255))) / 255
+	default:
+		return s
+	}
+}
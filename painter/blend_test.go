@@ -0,0 +1,83 @@
+package painter
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func blendOnto(bg color.Color, src color.Color, mode BlendMode) color.RGBA {
+	tx := NewImageTexture(image.Pt(4, 4))
+	(&FillOp{Color: bg}).Do(tx)
+	fill(tx, tx.Bounds(), src, mode)
+	return tx.RGBA().RGBAAt(1, 1)
+}
+
+func TestBlendModes(t *testing.T) {
+	bg := color.RGBA{R: 200, G: 100, B: 0, A: 255}
+	src := color.RGBA{R: 100, G: 255, B: 255, A: 255}
+
+	tests := []struct {
+		mode   BlendMode
+		expect color.RGBA
+	}{
+		{mode: BlendOver, expect: src},
+		{mode: BlendSrc, expect: src},
+		{mode: BlendMultiply, expect: color.RGBA{R: 78, G: 100, B: 0, A: 255}},
+		{mode: BlendScreen, expect: color.RGBA{R: 222, G: 255, B: 255, A: 255}},
+		{mode: BlendXor, expect: color.RGBA{R: 200 ^ 100, G: 100 ^ 255, B: 255, A: 255}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.mode.String(), func(t *testing.T) {
+			assert.Equal(t, tc.expect, blendOnto(bg, src, tc.mode))
+		})
+	}
+}
+
+func TestBlendOpacity(t *testing.T) {
+	half := WithOpacity(color.RGBA{R: 255, A: 255}, 0.5)
+
+	over := blendOnto(color.White, half, BlendOver)
+	assert.Equal(t, color.RGBA{R: 255, G: 127, B: 127, A: 255}, over)
+
+	multiply := blendOnto(color.White, half, BlendMultiply)
+	assert.Equal(t, color.RGBA{R: 255, G: 127, B: 127, A: 255}, multiply, "multiplying by white keeps the source color")
+}
+
+func TestBlendFallsBackToOver(t *testing.T) {
+	textureMock := new(Mock)
+	textureMock.On("Fill", image.Rect(0, 0, 1, 1), color.Black, draw.Over).Return()
+
+	fill(textureMock, image.Rect(0, 0, 1, 1), color.Black, BlendMultiply)
+
+	textureMock.AssertExpectations(t)
+}
+
+func TestParseBlendMode(t *testing.T) {
+	for _, mode := range []BlendMode{BlendOver, BlendSrc, BlendMultiply, BlendScreen, BlendXor} {
+		parsed, err := ParseBlendMode(mode.String())
+		require.NoError(t, err)
+		assert.Equal(t, mode, parsed)
+	}
+
+	_, err := ParseBlendMode("overlay")
+	assert.Error(t, err)
+}
+
+func TestLoopCanBlend(t *testing.T) {
+	headless := &Loop{Receiver: new(Mock)}
+	headless.Start(context.Background(), HeadlessScreen{})
+	defer headless.StopAndWait()
+	assert.True(t, headless.CanBlend(BlendMultiply))
+
+	loop, _, _, _ := newLoopWithMocks(t)
+	assert.True(t, loop.CanBlend(BlendOver))
+	assert.True(t, loop.CanBlend(BlendSrc))
+	assert.False(t, loop.CanBlend(BlendMultiply), "textures without Blender support only draw.Op modes")
+}
@@ -0,0 +1,300 @@
+// Package canvas дозволяє одному процесу painter обслуговувати кілька незалежних полотен.
+// Кожне полотно має власний стан lang.Parser та власний painter.Loop з програмними текстурами,
+// а у вікні відображається лише одне обране полотно.
+package canvas
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"unicode"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/roman-mazur/architecture-lab-3/painter/lang"
+	"golang.org/x/exp/shiny/screen"
+)
+
+// DefaultName — назва полотна, яке створюється разом з реєстром і обслуговує запити до "/".
+const DefaultName = "default"
+
+var (
+	// ErrExists повертається при спробі створити полотно з уже зайнятою назвою.
+	ErrExists = errors.New("canvas already exists")
+	// ErrNotFound повертається, якщо полотна з такою назвою немає.
+	ErrNotFound = errors.New("canvas not found")
+)
+
+// Display показує кадри полотна, обраного для відображення.
+type Display interface {
+	Present(frame *image.RGBA)
+}
+
+// Frame — кадр полотна разом з його порядковим номером (починаючи з 1).
+// Зображення кадру не змінюється після публікації, тому його можна читати без синхронізації.
+type Frame struct {
+	Number uint64
+	Image  *image.RGBA
+}
+
+// Canvas — окреме полотно зі своїм парсером та циклом подій.
+type Canvas struct {
+	Name   string
+	Parser *lang.Parser
+	Loop   *painter.Loop
+
+	registry *Registry
+	// epoch — випадковий ідентифікатор цього екземпляра полотна. Нумерація кадрів починається заново після
+	// перезапуску процесу чи повторного створення полотна, тож epoch відрізняє ETag кадрів з однаковим номером.
+	epoch string
+
+	mu          sync.Mutex
+	frame       Frame
+	subscribers map[chan Frame]struct{}
+}
+
+// Update реалізує painter.Receiver: зберігає копію кадру, розсилає її підписникам
+// і передає у Display, якщо полотно зараз відображається.
+func (c *Canvas) Update(t screen.Texture) {
+	tx, ok := t.(*painter.ImageTexture)
+	if !ok {
+		return
+	}
+	img := tx.Snapshot()
+
+	c.mu.Lock()
+	c.frame = Frame{Number: c.frame.Number + 1, Image: img}
+	frame := c.frame
+	for ch := range c.subscribers {
+		offer(ch, frame)
+	}
+	c.mu.Unlock()
+
+	if d := c.registry.displayFor(c.Name); d != nil {
+		d.Present(img)
+	}
+}
+
+// Frame повертає останній кадр полотна. Якщо жодного кадру ще не було, повертає false.
+func (c *Canvas) Frame() (Frame, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.frame, c.frame.Image != nil
+}
+
+// Subscribe повертає канал, у який надходитимуть нові кадри полотна, починаючи з останнього вже готового.
+// Повільний підписник не гальмує цикл подій: якщо він не встиг забрати кадр, той замінюється новішим.
+// Щоб відписатися, потрібно викликати повернуту функцію.
+func (c *Canvas) Subscribe() (<-chan Frame, func()) {
+	ch := make(chan Frame, 1)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.subscribers == nil {
+		c.subscribers = make(map[chan Frame]struct{})
+	}
+	c.subscribers[ch] = struct{}{}
+	if c.frame.Image != nil {
+		ch <- c.frame
+	}
+
+	return ch, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.subscribers, ch)
+	}
+}
+
+// offer кладе кадр у канал ємністю 1, витісняючи ще не прочитаний попередній кадр.
+func offer(ch chan Frame, f Frame) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- f
+}
+
+// Config містить налаштування, спільні для всіх полотен реєстру.
+type Config struct {
+	// StateDir — каталог для збереження сцен командами save та load і під час Autosave.
+	// Якщо порожній, збереження сцен вимкнене.
+	StateDir string
+	// JournalDir — каталог, у якому для кожного полотна ведеться журнал виконаних скриптів <назва полотна>.journal
+	// (див. lang.Journal). Якщо порожній, журнали не ведуться.
+	JournalDir string
+	// FrameRate — частота кадрів анімацій кожного полотна (див. painter.Loop.FrameRate).
+	FrameRate int
+	// MaxFPS обмежує частоту кадрів, які передаються у вікно та трансляції (див. painter.Loop.MaxFPS).
+	MaxFPS int
+	// QueueCapacity та Overflow обмежують чергу операцій кожного полотна (див. painter.Loop.Capacity).
+	QueueCapacity int
+	Overflow      painter.OverflowPolicy
+}
+
+// Registry зберігає іменовані полотна та відстежує, яке з них показано у вікні.
+type Registry struct {
+	ctx    context.Context
+	config Config
+
+	mu       sync.Mutex
+	canvases map[string]*Canvas
+	shown    string
+	display  Display
+
+	requests requestCounter // HTTP запити до Handler (див. metricsHandler)
+}
+
+// NewRegistry створює реєстр з полотном DefaultName. Цикли подій полотен зупиняються, коли ctx скасовано.
+func NewRegistry(ctx context.Context, config Config) *Registry {
+	r := &Registry{ctx: ctx, config: config, canvases: make(map[string]*Canvas), shown: DefaultName}
+	if _, err := r.Create(DefaultName); err != nil {
+		log.Printf("Cannot create canvas %s: %s", DefaultName, err)
+	}
+	return r
+}
+
+// Create створює нове полотно та запускає його цикл подій.
+func (r *Registry) Create(name string) (*Canvas, error) {
+	if !validName(name) {
+		return nil, fmt.Errorf("invalid canvas name: %q", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.canvases[name]; ok {
+		return nil, ErrExists
+	}
+
+	c := &Canvas{Name: name, Parser: &lang.Parser{StateDir: r.config.StateDir}, Loop: &painter.Loop{
+		FrameRate: r.config.FrameRate,
+		MaxFPS:    r.config.MaxFPS,
+		Capacity:  r.config.QueueCapacity,
+		Overflow:  r.config.Overflow,
+	}, registry: r, epoch: newEpoch()}
+	if r.config.JournalDir != "" {
+		if err := os.MkdirAll(r.config.JournalDir, 0o755); err != nil {
+			return nil, err
+		}
+		j, err := lang.OpenJournal(filepath.Join(r.config.JournalDir, name+".journal"))
+		if err != nil {
+			return nil, err
+		}
+		c.Parser.Journal = j
+	}
+	c.Loop.Receiver = c
+	c.Loop.Start(r.ctx, painter.HeadlessScreen{})
+	c.Parser.CanBlend = c.Loop.CanBlend
+	r.canvases[name] = c
+	return c, nil
+}
+
+// newEpoch повертає випадковий ідентифікатор екземпляра полотна (див. Canvas.epoch).
+func newEpoch() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Get повертає полотно з назвою name.
+func (r *Registry) Get(name string) (*Canvas, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.canvases[name]
+	return c, ok
+}
+
+// Names повертає відсортований список назв полотен.
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.canvases))
+	for name := range r.canvases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Shown повертає назву полотна, яке відображається у вікні.
+func (r *Registry) Shown() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.shown
+}
+
+// Show обирає полотно для відображення та одразу показує його останній кадр.
+func (r *Registry) Show(name string) error {
+	r.mu.Lock()
+	c, ok := r.canvases[name]
+	if ok {
+		r.shown = name
+	}
+	d := r.display
+	r.mu.Unlock()
+
+	if !ok {
+		return ErrNotFound
+	}
+	if frame, ok := c.Frame(); d != nil && ok {
+		d.Present(frame.Image)
+	}
+	return nil
+}
+
+// SetDisplay задає, куди передавати кадри обраного полотна, та показує його останній кадр.
+func (r *Registry) SetDisplay(d Display) {
+	r.mu.Lock()
+	r.display = d
+	shown := r.shown
+	r.mu.Unlock()
+
+	_ = r.Show(shown)
+}
+
+// Close зупиняє цикли подій усіх полотен, не чекаючи на операції в їхніх чергах (див. painter.Loop.StopNow),
+// та закриває їхні журнали.
+func (r *Registry) Close() {
+	r.mu.Lock()
+	canvases := make([]*Canvas, 0, len(r.canvases))
+	for _, c := range r.canvases {
+		canvases = append(canvases, c)
+	}
+	r.mu.Unlock()
+
+	// Сцени зберігаються зі стану парсерів (див. Autosave), тож операції, що ще чекають у черзі, не потрібні.
+	for _, c := range canvases {
+		c.Loop.StopNow()
+		if c.Parser.Journal != nil {
+			_ = c.Parser.Journal.Close()
+		}
+	}
+}
+
+// displayFor повертає Display, якщо полотно name зараз відображається.
+func (r *Registry) displayFor(name string) Display {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.shown != name {
+		return nil
+	}
+	return r.display
+}
+
+// validName перевіряє, що назва полотна непорожня і містить лише літери, цифри, "_" та "-".
+func validName(name string) bool {
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return name != ""
+}
@@ -0,0 +1,387 @@
+package canvas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/bmp"
+)
+
+// displayMock запам'ятовує кадри, передані для відображення.
+type displayMock struct {
+	frames chan *image.RGBA
+}
+
+func (d *displayMock) Present(frame *image.RGBA) {
+	d.frames <- frame
+}
+
+func newTestServer(t *testing.T) (*Registry, *httptest.Server) {
+	ctx, cancel := context.WithCancel(context.Background())
+	registry := NewRegistry(ctx, Config{})
+	server := httptest.NewServer(registry.Handler())
+	t.Cleanup(func() {
+		server.Close()
+		registry.Close()
+		cancel()
+	})
+	return registry, server
+}
+
+func post(t *testing.T, url, body string) *http.Response {
+	t.Helper()
+	resp, err := http.Post(url, "text/plain", strings.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp
+}
+
+// waitFrame очікує, доки полотно отримає кадр, що задовольняє умову ok.
+func waitFrame(t *testing.T, c *Canvas, ok func(*image.RGBA) bool) *image.RGBA {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if frame, ready := c.Frame(); ready && ok(frame.Image) {
+			return frame.Image
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("frame was not rendered")
+	return nil
+}
+
+func TestCreateAndList(t *testing.T) {
+	_, server := newTestServer(t)
+
+	assert.Equal(t, http.StatusCreated, post(t, server.URL+"/canvas?name=team-a", "").StatusCode)
+	assert.Equal(t, http.StatusConflict, post(t, server.URL+"/canvas?name=team-a", "").StatusCode)
+	assert.Equal(t, http.StatusBadRequest, post(t, server.URL+"/canvas?name=bad/name", "").StatusCode)
+
+	resp, err := http.Get(server.URL + "/canvas")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var list []canvasInfo
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	assert.Equal(t, []canvasInfo{{Name: "default", Shown: true}, {Name: "team-a"}}, list)
+}
+
+func TestCanvasesAreIsolated(t *testing.T) {
+	registry, server := newTestServer(t)
+	require.Equal(t, http.StatusCreated, post(t, server.URL+"/canvas?name=team-a", "").StatusCode)
+
+	assert.Equal(t, http.StatusOK, post(t, server.URL+"/canvas/team-a", "fill red\nfigure id=a 0.5 0.5\nupdate").StatusCode)
+	assert.Equal(t, http.StatusOK, post(t, server.URL+"/", "fill white\nupdate").StatusCode)
+	assert.Equal(t, http.StatusBadRequest, post(t, server.URL+"/", "move a 0.1 0.1").StatusCode, "objects of team-a are not visible on the default canvas")
+	assert.Equal(t, http.StatusNotFound, post(t, server.URL+"/canvas/team-b", "update").StatusCode)
+
+	teamA, _ := registry.Get("team-a")
+	def, _ := registry.Get(DefaultName)
+	red := color.RGBA{R: 255, A: 255}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+	waitFrame(t, teamA, func(f *image.RGBA) bool { return f.RGBAAt(0, 0) == red })
+	waitFrame(t, def, func(f *image.RGBA) bool { return f.RGBAAt(0, 0) == white })
+}
+
+func TestShowSelectsDisplayedCanvas(t *testing.T) {
+	registry, server := newTestServer(t)
+	display := &displayMock{frames: make(chan *image.RGBA, 10)}
+	registry.SetDisplay(display)
+	require.Equal(t, http.StatusCreated, post(t, server.URL+"/canvas?name=team-a", "").StatusCode)
+
+	post(t, server.URL+"/canvas/team-a", "fill red\nupdate")
+	teamA, _ := registry.Get("team-a")
+	waitFrame(t, teamA, func(*image.RGBA) bool { return true })
+	assert.Empty(t, display.frames, "hidden canvas must not reach the display")
+
+	assert.Equal(t, http.StatusOK, post(t, server.URL+"/canvas/team-a/show", "").StatusCode)
+	assert.Equal(t, "team-a", registry.Shown())
+
+	select {
+	case frame := <-display.frames:
+		assert.Equal(t, color.RGBA{R: 255, A: 255}, frame.RGBAAt(0, 0))
+	case <-time.After(time.Second):
+		t.Fatal("last frame of the shown canvas was not displayed")
+	}
+
+	assert.Equal(t, http.StatusNotFound, post(t, server.URL+"/canvas/missing/show", "").StatusCode)
+}
+
+func TestStreamHandler(t *testing.T) {
+	decoders := map[string]func(io.Reader) (image.Image, error){"png": png.Decode, "bmp": bmp.Decode}
+	for format, decode := range decoders {
+		t.Run(format, func(t *testing.T) {
+			_, server := newTestServer(t)
+
+			client := &http.Client{Timeout: 2 * time.Second}
+			resp, err := client.Get(server.URL + "/stream?format=" + format)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+			require.NoError(t, err)
+			assert.Equal(t, "multipart/x-mixed-replace", mediaType)
+
+			post(t, server.URL+"/", "fill red\nupdate")
+			post(t, server.URL+"/", "fill blue\nupdate")
+
+			// Повільний клієнт може пропустити проміжний кадр, але останній кадр має дійти.
+			reader := multipart.NewReader(resp.Body, params["boundary"])
+			for {
+				part, err := reader.NextPart()
+				require.NoError(t, err)
+				assert.Equal(t, "image/"+format, part.Header.Get("Content-Type"))
+				assert.NotEmpty(t, part.Header.Get("X-Frame-Number"))
+
+				img, err := decode(part)
+				require.NoError(t, err)
+				if color.RGBAModel.Convert(img.At(0, 0)) == (color.RGBA{B: 255, A: 255}) {
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestStreamHandlerRejectsUnknownFormat(t *testing.T) {
+	_, server := newTestServer(t)
+
+	resp, err := http.Get(server.URL + "/stream?format=gif")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestSnapshotHandler(t *testing.T) {
+	registry, server := newTestServer(t)
+
+	resp, err := http.Get(server.URL + "/snapshot")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "no frame yet")
+
+	post(t, server.URL+"/", "fill red\nupdate")
+	def, _ := registry.Get(DefaultName)
+	waitFrame(t, def, func(*image.RGBA) bool { return true })
+
+	for format, decode := range map[string]func(io.Reader) (image.Image, error){
+		"png":  png.Decode,
+		"jpeg": jpeg.Decode,
+		"bmp":  bmp.Decode,
+	} {
+		t.Run(format, func(t *testing.T) {
+			resp, err := http.Get(server.URL + "/snapshot?format=" + format)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "image/"+format, resp.Header.Get("Content-Type"))
+			assert.Equal(t, "1", resp.Header.Get("X-Frame-Number"))
+			assert.Equal(t, `"default-`+def.epoch+`-1-`+format+`"`, resp.Header.Get("ETag"))
+
+			img, err := decode(resp.Body)
+			require.NoError(t, err)
+			r, g, b, _ := img.At(10, 10).RGBA()
+			assert.Greater(t, r, uint32(0xf000))
+			assert.Less(t, g|b, uint32(0x1000))
+		})
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/snapshot", nil)
+	req.Header.Set("If-None-Match", `"default-`+def.epoch+`-1-png"`)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	// Після перезапуску нумерація кадрів починається знову, але ETag старого кадру вже не збігається.
+	restarted, restartedServer := newTestServer(t)
+	post(t, restartedServer.URL+"/", "fill green\nupdate")
+	c, _ := restarted.Get(DefaultName)
+	waitFrame(t, c, func(*image.RGBA) bool { return true })
+	req, _ = http.NewRequest(http.MethodGet, restartedServer.URL+"/snapshot", nil)
+	req.Header.Set("If-None-Match", `"default-`+def.epoch+`-1-png"`)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("X-Frame-Number"))
+	assert.NotEqual(t, `"default-`+def.epoch+`-1-png"`, resp.Header.Get("ETag"))
+
+	resp, err = http.Get(server.URL + "/snapshot?format=gif")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestStatsHandler(t *testing.T) {
+	registry, server := newTestServer(t)
+	def, _ := registry.Get(DefaultName)
+
+	post(t, server.URL+"/", "fill red\nupdate")
+	waitFrame(t, def, func(*image.RGBA) bool { return true })
+
+	resp, err := http.Get(server.URL + "/stats")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var stats painter.FrameStats
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	assert.Equal(t, uint64(1), stats.Presented)
+}
+
+func TestHistoryEndpoints(t *testing.T) {
+	registry, server := newTestServer(t)
+	def, _ := registry.Get(DefaultName)
+	red := color.RGBA{R: 255, A: 255}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+	post(t, server.URL+"/", "fill white\nupdate")
+	assert.Equal(t, http.StatusOK, post(t, server.URL+"/checkpoint/clean", "").StatusCode)
+	post(t, server.URL+"/", "fill red\nupdate")
+	waitFrame(t, def, func(f *image.RGBA) bool { return f.RGBAAt(0, 0) == red })
+
+	assert.Equal(t, http.StatusOK, post(t, server.URL+"/undo", "").StatusCode)
+	waitFrame(t, def, func(f *image.RGBA) bool { return f.RGBAAt(0, 0) == white })
+
+	assert.Equal(t, http.StatusOK, post(t, server.URL+"/redo", "").StatusCode)
+	waitFrame(t, def, func(f *image.RGBA) bool { return f.RGBAAt(0, 0) == red })
+
+	assert.Equal(t, http.StatusOK, post(t, server.URL+"/restore/clean", "").StatusCode)
+	waitFrame(t, def, func(f *image.RGBA) bool { return f.RGBAAt(0, 0) == white })
+
+	assert.Equal(t, http.StatusBadRequest, post(t, server.URL+"/restore/missing", "").StatusCode)
+	assert.Equal(t, http.StatusBadRequest, post(t, server.URL+"/canvas/default/redo", "").StatusCode)
+	assert.Equal(t, http.StatusNotFound, post(t, server.URL+"/canvas/missing/undo", "").StatusCode)
+}
+
+func TestAutosaveAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := NewRegistry(ctx, Config{StateDir: dir})
+	team, err := registry.Create("team-a")
+	require.NoError(t, err)
+	_, err = team.Parser.Parse(strings.NewReader("fill blue\nfigure id=f 0.5 0.5"))
+	require.NoError(t, err)
+	require.NoError(t, registry.Autosave())
+	registry.Close()
+
+	restored := NewRegistry(ctx, Config{StateDir: dir})
+	defer restored.Close()
+	require.NoError(t, restored.Restore())
+	assert.Equal(t, []string{"default", "team-a"}, restored.Names())
+
+	c, _ := restored.Get("team-a")
+	waitFrame(t, c, func(img *image.RGBA) bool {
+		return img.RGBAAt(0, 0) == color.RGBA{B: 0xff, A: 0xff}
+	})
+	assert.Error(t, restored.Load(DefaultName, "missing"))
+}
+
+func TestMetricsHandler(t *testing.T) {
+	_, server := newTestServer(t)
+
+	post(t, server.URL+"/?wait=true", "fill red\nupdate")
+	assert.Equal(t, http.StatusBadRequest, post(t, server.URL+"/", "figure 0.5\nstar\nupdate").StatusCode)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	conn.Close()
+
+	metrics := func() []string {
+		resp, err := http.Get(server.URL + "/metrics")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain"))
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return strings.Split(string(body), "\n")
+	}
+	// WebSocket запит враховується, коли обробник помітить закриття з'єднання.
+	require.Eventually(t, func() bool {
+		return slices.Contains(metrics(), `painter_http_requests_total{method="GET",code="101"} 1`)
+	}, time.Second, 10*time.Millisecond)
+
+	lines := metrics()
+	for _, line := range []string{
+		"# TYPE painter_queue_length gauge",
+		`painter_queue_length{canvas="default"} 0`,
+		`painter_frames_presented_total{canvas="default"} 1`,
+		`painter_op_duration_seconds_count{canvas="default",op="FillOp"} 1`,
+		`painter_op_duration_seconds_bucket{canvas="default",op="FillOp",le="+Inf"} 1`,
+		`painter_op_duration_seconds_count{canvas="default",op="updateOp"} 1`,
+		`painter_parse_errors_total{canvas="default",command="figure"} 1`,
+		`painter_parse_errors_total{canvas="default",command="unknown"} 1`,
+		`painter_http_requests_total{method="POST",code="200"} 1`,
+		`painter_http_requests_total{method="POST",code="400"} 1`,
+	} {
+		assert.Contains(t, lines, line)
+	}
+	for _, line := range lines {
+		assert.NotContains(t, line, `op="scriptOp"`, "scripts are counted by their operations")
+	}
+}
+
+// failingScreen — екран без вікна, який не створює текстуру після limit успішних викликів NewTexture.
+type failingScreen struct {
+	painter.HeadlessScreen
+	limit    int
+	textures []*releaseCounter
+}
+
+// releaseCounter рахує виклики Release текстури.
+type releaseCounter struct {
+	screen.Texture
+	released int
+}
+
+func (t *releaseCounter) Release() {
+	t.released++
+}
+
+func (s *failingScreen) NewTexture(size image.Point) (screen.Texture, error) {
+	if len(s.textures) == s.limit {
+		return nil, errors.New("out of textures")
+	}
+	t := &releaseCounter{Texture: painter.NewImageTexture(size)}
+	s.textures = append(s.textures, t)
+	return t, nil
+}
+
+func TestPresenterReleasesTexturesOnError(t *testing.T) {
+	s := &failingScreen{limit: 1}
+	p := NewPresenter(s, &displayReceiver{})
+
+	p.Present(image.NewRGBA(image.Rect(0, 0, 4, 4)))
+
+	require.Len(t, s.textures, 1)
+	assert.Equal(t, 1, s.textures[0].released, "the texture created before the failure is released")
+}
+
+// displayReceiver ігнорує кадри, передані Presenter.
+type displayReceiver struct{}
+
+func (displayReceiver) Update(screen.Texture) {}
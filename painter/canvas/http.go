@@ -0,0 +1,152 @@
+package canvas
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/roman-mazur/architecture-lab-3/painter/lang"
+)
+
+// canvasInfo описує полотно у відповіді GET /canvas.
+type canvasInfo struct {
+	Name  string `json:"name"`
+	Shown bool   `json:"shown"`
+}
+
+// Handler конструює обробник HTTP запитів до реєстру:
+//   - GET /canvas — список полотен;
+//   - POST /canvas?name=<назва> — створення полотна;
+//   - GET|POST /canvas/{name} — виконання скрипта на полотні (як lang.HttpHandler), зокрема у JSON форматі;
+//   - GET /schema.json — JSON Schema формату команд (lang.Schema);
+//   - GET /metrics — метрики реєстру у текстовому форматі Prometheus (див. metricsHandler);
+//   - GET /canvas/{name}/ws — WebSocket з'єднання для потокової передачі скрипта (як lang.WebSocketHandler);
+//   - GET /canvas/{name}/stream — трансляція кадрів полотна (див. StreamHandler);
+//   - GET /canvas/{name}/snapshot — останній кадр полотна (див. SnapshotHandler);
+//   - GET /canvas/{name}/stats — лічильники кадрів та стан черги циклу подій полотна (див. stats);
+//   - POST /canvas/{name}/undo, /redo, /checkpoint/{checkpoint}, /restore/{checkpoint} — керування історією
+//     змін полотна (як однойменні команди скрипта, див. lang.HistoryHandler);
+//   - POST /canvas/{name}/show — відображення полотна у вікні;
+//   - /, /ws, /stream, /snapshot, /stats, /undo, /redo, /checkpoint/{checkpoint}, /restore/{checkpoint} — те саме
+//     для полотна DefaultName.
+func (r *Registry) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /canvas", func(rw http.ResponseWriter, req *http.Request) {
+		shown := r.Shown()
+		list := make([]canvasInfo, 0)
+		for _, name := range r.Names() {
+			list = append(list, canvasInfo{Name: name, Shown: name == shown})
+		}
+		writeJSON(rw, http.StatusOK, list)
+	})
+
+	mux.HandleFunc("POST /canvas", func(rw http.ResponseWriter, req *http.Request) {
+		name := req.FormValue("name")
+		c, err := r.Create(name)
+		switch {
+		case errors.Is(err, ErrExists):
+			rw.WriteHeader(http.StatusConflict)
+		case err != nil:
+			log.Printf("Cannot create canvas: %s", err)
+			rw.WriteHeader(http.StatusBadRequest)
+		default:
+			writeJSON(rw, http.StatusCreated, canvasInfo{Name: c.Name, Shown: r.Shown() == c.Name})
+		}
+	})
+
+	mux.Handle("/canvas/{name}", r.canvasHandler(func(c *Canvas) http.Handler {
+		return lang.HttpHandler(c.Loop, c.Parser)
+	}))
+
+	mux.Handle("GET /canvas/{name}/ws", r.canvasHandler(func(c *Canvas) http.Handler {
+		return lang.WebSocketHandler(c.Loop, c.Parser)
+	}))
+
+	mux.Handle("GET /canvas/{name}/stream", r.canvasHandler(StreamHandler))
+
+	mux.Handle("GET /canvas/{name}/snapshot", r.canvasHandler(SnapshotHandler))
+
+	mux.Handle("GET /canvas/{name}/stats", r.canvasHandler(statsHandler))
+
+	for _, route := range historyRoutes {
+		action := strings.SplitN(route, "/", 2)[0]
+		h := r.canvasHandler(func(c *Canvas) http.Handler {
+			return lang.HistoryHandler(c.Loop, c.Parser, action)
+		})
+		mux.Handle("POST /canvas/{name}/"+route, h)
+		mux.Handle("POST /"+route, h)
+	}
+
+	mux.Handle("POST /canvas/{name}/show", r.canvasHandler(func(c *Canvas) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if err := r.Show(c.Name); err != nil {
+				rw.WriteHeader(http.StatusNotFound)
+				return
+			}
+			rw.WriteHeader(http.StatusOK)
+		})
+	}))
+
+	mux.Handle("/{$}", r.canvasHandler(func(c *Canvas) http.Handler {
+		return lang.HttpHandler(c.Loop, c.Parser)
+	}))
+
+	mux.Handle("GET /ws", r.canvasHandler(func(c *Canvas) http.Handler {
+		return lang.WebSocketHandler(c.Loop, c.Parser)
+	}))
+
+	mux.Handle("GET /stream", r.canvasHandler(StreamHandler))
+	mux.Handle("GET /snapshot", r.canvasHandler(SnapshotHandler))
+	mux.Handle("GET /stats", r.canvasHandler(statsHandler))
+
+	mux.Handle("GET /schema.json", lang.SchemaHandler())
+
+	mux.Handle("GET /metrics", r.metricsHandler())
+
+	return r.requests.wrap(mux)
+}
+
+// stats — відповідь GET /canvas/{name}/stats.
+type stats struct {
+	painter.FrameStats
+	Queue painter.QueueStats `json:"queue"`
+}
+
+// statsHandler віддає лічильники кадрів та стан черги циклу подій полотна c.
+func statsHandler(c *Canvas) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		writeJSON(rw, http.StatusOK, stats{FrameStats: c.Loop.Stats(), Queue: c.Loop.QueueStats()})
+	})
+}
+
+// historyRoutes — шляхи обробників історії змін відносно полотна; перший сегмент шляху є назвою команди.
+var historyRoutes = []string{"undo", "redo", "checkpoint/{checkpoint}", "restore/{checkpoint}"}
+
+// canvasHandler знаходить полотно за параметром шляху {name} (або DefaultName, якщо його немає)
+// і передає запит обробнику, створеному для цього полотна.
+func (r *Registry) canvasHandler(h func(c *Canvas) http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		name := req.PathValue("name")
+		if name == "" {
+			name = DefaultName
+		}
+		c, ok := r.Get(name)
+		if !ok {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		h(c).ServeHTTP(rw, req)
+	})
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		log.Printf("Cannot write response: %s", err)
+	}
+}
@@ -0,0 +1,233 @@
+package canvas
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+)
+
+// metricsHandler віддає метрики реєстру у текстовому форматі Prometheus:
+//   - painter_queue_length, painter_queue_capacity, painter_queue_dropped_total, painter_queue_rejected_total —
+//     стан черги циклу подій кожного полотна (painter.QueueStats);
+//   - painter_op_duration_seconds — гістограма часу виконання операцій за типом (painter.Loop.OpLatency);
+//   - painter_frames_presented_total, painter_frames_merged_total, painter_frames_dropped_total —
+//     лічильники кадрів (painter.FrameStats);
+//   - painter_parse_errors_total — помилки скриптів за командою (lang.Parser.ErrorCounts);
+//   - painter_http_requests_total — HTTP запити до реєстру за методом та кодом відповіді.
+func (r *Registry) metricsHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w := bufio.NewWriter(rw)
+		r.writeMetrics(w)
+		if err := w.Flush(); err != nil {
+			log.Printf("Cannot write metrics: %s", err)
+		}
+	})
+}
+
+func (r *Registry) writeMetrics(w io.Writer) {
+	var canvases []*Canvas
+	for _, name := range r.Names() {
+		if c, ok := r.Get(name); ok {
+			canvases = append(canvases, c)
+		}
+	}
+
+	queues := make([]painter.QueueStats, len(canvases))
+	frames := make([]painter.FrameStats, len(canvases))
+	for i, c := range canvases {
+		queues[i], frames[i] = c.Loop.QueueStats(), c.Loop.Stats()
+	}
+
+	perCanvas := func(name, kind, help string, value func(i int) any) {
+		writeHeader(w, name, kind, help)
+		for i, c := range canvases {
+			writeSample(w, name, labels{"canvas", c.Name}, value(i))
+		}
+	}
+	perCanvas("painter_queue_length", "gauge", "Operations waiting in the canvas loop queue, including reserved slots.",
+		func(i int) any { return queues[i].Len })
+	perCanvas("painter_queue_capacity", "gauge", "Capacity of the canvas loop queue (0 means unbounded).",
+		func(i int) any { return queues[i].Capacity })
+	perCanvas("painter_queue_dropped_total", "counter", "Operations dropped from a full queue.",
+		func(i int) any { return queues[i].Dropped })
+	perCanvas("painter_queue_rejected_total", "counter", "Operations rejected because the queue was full.",
+		func(i int) any { return queues[i].Rejected })
+	perCanvas("painter_frames_presented_total", "counter", "Frames published to the canvas.",
+		func(i int) any { return frames[i].Presented })
+	perCanvas("painter_frames_merged_total", "counter", "Ready frames replaced by a newer frame before being published.",
+		func(i int) any { return frames[i].Merged })
+	perCanvas("painter_frames_dropped_total", "counter", "Frames that were never published.",
+		func(i int) any { return frames[i].Dropped })
+
+	const opDuration = "painter_op_duration_seconds"
+	writeHeader(w, opDuration, "histogram", "Time the canvas loop spent executing an operation, by operation type.")
+	for _, c := range canvases {
+		latency := c.Loop.OpLatency()
+		for _, op := range slices.Sorted(maps.Keys(latency)) {
+			h := latency[op]
+			for i, le := range painter.LatencyBuckets {
+				writeSample(w, opDuration+"_bucket", labels{"canvas", c.Name, "op", op, "le", formatFloat(le.Seconds())}, h.Buckets[i])
+			}
+			writeSample(w, opDuration+"_bucket", labels{"canvas", c.Name, "op", op, "le", "+Inf"}, h.Count)
+			writeSample(w, opDuration+"_sum", labels{"canvas", c.Name, "op", op}, h.Sum.Seconds())
+			writeSample(w, opDuration+"_count", labels{"canvas", c.Name, "op", op}, h.Count)
+		}
+	}
+
+	const parseErrors = "painter_parse_errors_total"
+	writeHeader(w, parseErrors, "counter", "Script errors, by command.")
+	for _, c := range canvases {
+		counts := c.Parser.ErrorCounts()
+		for _, command := range slices.Sorted(maps.Keys(counts)) {
+			writeSample(w, parseErrors, labels{"canvas", c.Name, "command", command}, counts[command])
+		}
+	}
+
+	const httpRequests = "painter_http_requests_total"
+	writeHeader(w, httpRequests, "counter", "HTTP requests served, by method and status code.")
+	requests := r.requests.snapshot()
+	keys := slices.SortedFunc(maps.Keys(requests), func(a, b requestKey) int {
+		if a.method != b.method {
+			return strings.Compare(a.method, b.method)
+		}
+		return a.code - b.code
+	})
+	for _, k := range keys {
+		writeSample(w, httpRequests, labels{"method", k.method, "code", strconv.Itoa(k.code)}, requests[k])
+	}
+}
+
+// labels — пари назва, значення міток зразка метрики.
+type labels []string
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (l labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(l); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, l[i], labelEscaper.Replace(l[i+1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeSample(w io.Writer, name string, l labels, value any) {
+	if f, ok := value.(float64); ok {
+		value = formatFloat(f)
+	}
+	fmt.Fprintf(w, "%s%s %v\n", name, l, value)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// requestKey — метод та код відповіді HTTP запиту.
+type requestKey struct {
+	method string
+	code   int
+}
+
+// requestCounter рахує HTTP запити за методом та кодом відповіді.
+type requestCounter struct {
+	mu     sync.Mutex
+	counts map[requestKey]uint64
+}
+
+// wrap повертає обробник, який передає запити h і рахує їх.
+func (rc *requestCounter) wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		sr := &statusRecorder{ResponseWriter: rw}
+		h.ServeHTTP(sr, req)
+		if sr.code == 0 {
+			sr.code = http.StatusOK
+		}
+
+		method := req.Method
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+			http.MethodDelete, http.MethodOptions:
+		default:
+			// Довільні методи клієнтів не створюють нових міток.
+			method = "OTHER"
+		}
+
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+		if rc.counts == nil {
+			rc.counts = make(map[requestKey]uint64)
+		}
+		rc.counts[requestKey{method: method, code: sr.code}]++
+	})
+}
+
+func (rc *requestCounter) snapshot() map[requestKey]uint64 {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return maps.Clone(rc.counts)
+}
+
+// statusRecorder запам'ятовує код відповіді. Підтримує http.Flusher для StreamHandler
+// та http.Hijacker для WebSocket з'єднань.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	if sr.code == 0 {
+		sr.code = code
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.code == 0 {
+		sr.code = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := sr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not implement http.Hijacker", sr.ResponseWriter)
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && sr.code == 0 {
+		sr.code = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
@@ -0,0 +1,80 @@
+package canvas
+
+import (
+	"image"
+	"sync"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+	"golang.org/x/exp/shiny/screen"
+)
+
+// Presenter реалізує Display для справжнього вікна: кадр копіюється у буфер екрана,
+// завантажується у текстуру цього екрана і передається отримувачу, наприклад ui.Visualizer.
+type Presenter struct {
+	Receiver painter.Receiver
+
+	mu   sync.Mutex
+	s    screen.Screen
+	buf  screen.Buffer
+	tx   [2]screen.Texture
+	next int
+}
+
+// NewPresenter створює Presenter, який виділяє буфер і текстури на екрані s.
+func NewPresenter(s screen.Screen, receiver painter.Receiver) *Presenter {
+	return &Presenter{Receiver: receiver, s: s}
+}
+
+// Present показує кадр. Текстури чергуються, тож отримувач може малювати попередній кадр, поки завантажується новий.
+func (p *Presenter) Present(frame *image.RGBA) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.ensure(frame.Rect.Size()); err != nil {
+		return
+	}
+
+	copy(p.buf.RGBA().Pix, frame.Pix)
+	tx := p.tx[p.next]
+	p.next = 1 - p.next
+	tx.Upload(image.Point{}, p.buf, p.buf.Bounds())
+	p.Receiver.Update(tx)
+}
+
+// ensure виділяє буфер і текстури потрібного розміру. Викликається під p.mu.
+func (p *Presenter) ensure(size image.Point) error {
+	if p.buf != nil && p.buf.Size() == size {
+		return nil
+	}
+
+	buf, err := p.s.NewBuffer(size)
+	if err != nil {
+		return err
+	}
+	var tx [2]screen.Texture
+	for i := range tx {
+		if tx[i], err = p.s.NewTexture(size); err != nil {
+			for _, t := range tx[:i] {
+				t.Release()
+			}
+			buf.Release()
+			return err
+		}
+	}
+
+	p.release()
+	p.buf, p.tx = buf, tx
+	return nil
+}
+
+// release звільняє буфер і текстури. Викликається під p.mu.
+func (p *Presenter) release() {
+	if p.buf != nil {
+		p.buf.Release()
+	}
+	for _, tx := range p.tx {
+		if tx != nil {
+			tx.Release()
+		}
+	}
+}
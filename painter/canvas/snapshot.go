@@ -0,0 +1,68 @@
+package canvas
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/image/bmp"
+)
+
+// encoders містить підтримувані формати кадрів та відповідні їм MIME типи.
+var encoders = map[string]struct {
+	contentType string
+	encode      func(w io.Writer, img image.Image) error
+}{
+	"png":  {contentType: "image/png", encode: png.Encode},
+	"jpeg": {contentType: "image/jpeg", encode: func(w io.Writer, img image.Image) error { return jpeg.Encode(w, img, nil) }},
+	"bmp":  {contentType: "image/bmp", encode: bmp.Encode},
+}
+
+// SnapshotHandler конструює обробник, який повертає останній кадр полотна, переданий у painter.Receiver.
+// Формат задається параметром format=png|jpeg|bmp (за замовчуванням png). Номер кадру передається у заголовку
+// X-Frame-Number, а ETag дозволяє не завантажувати той самий кадр повторно (If-None-Match). ETag містить
+// випадковий ідентифікатор екземпляра полотна, тож не збігається з ETag кадрів до перезапуску процесу.
+func SnapshotHandler(c *Canvas) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "png"
+		}
+		enc, ok := encoders[format]
+		if !ok {
+			http.Error(rw, fmt.Sprintf("unsupported format: %s", format), http.StatusBadRequest)
+			return
+		}
+
+		frame, ok := c.Frame()
+		if !ok {
+			http.Error(rw, "no frame has been rendered yet", http.StatusNotFound)
+			return
+		}
+
+		etag := fmt.Sprintf(`"%s-%s-%d-%s"`, c.Name, c.epoch, frame.Number, format)
+		rw.Header().Set("ETag", etag)
+		rw.Header().Set("X-Frame-Number", strconv.FormatUint(frame.Number, 10))
+		rw.Header().Set("Cache-Control", "no-cache")
+		if r.Header.Get("If-None-Match") == etag {
+			rw.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := enc.encode(&buf, frame.Image); err != nil {
+			log.Printf("Cannot encode snapshot of canvas %s: %s", c.Name, err)
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rw.Header().Set("Content-Type", enc.contentType)
+		rw.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		_, _ = buf.WriteTo(rw)
+	})
+}
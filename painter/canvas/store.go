@@ -0,0 +1,67 @@
+package canvas
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+)
+
+// autosavePrefix — префікс назв сцен, під якими Autosave зберігає полотна.
+const autosavePrefix = "autosave-"
+
+// Autosave зберігає сцену кожного полотна у каталог Config.StateDir під назвою autosave-<назва полотна>.
+func (r *Registry) Autosave() error {
+	if r.config.StateDir == "" {
+		return nil
+	}
+	var errs []error
+	for _, name := range r.Names() {
+		c, _ := r.Get(name)
+		if err := c.Parser.Save(autosavePrefix + name); err != nil {
+			errs = append(errs, fmt.Errorf("canvas %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Restore відновлює полотна, збережені Autosave, створюючи ті з них, яких ще немає у реєстрі.
+func (r *Registry) Restore() error {
+	if r.config.StateDir == "" {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(r.config.StateDir, autosavePrefix+"*.json"))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, file := range files {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), autosavePrefix), ".json")
+		if err := r.Load(name, autosavePrefix+name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Load завантажує збережену сцену scene у полотно name, створивши його за потреби, та малює її.
+func (r *Registry) Load(name, scene string) error {
+	c, ok := r.Get(name)
+	if !ok {
+		var err error
+		if c, err = r.Create(name); err != nil {
+			return fmt.Errorf("canvas %s: %w", name, err)
+		}
+	}
+	ops, err := c.Parser.Parse(strings.NewReader("load " + scene + "\nupdate"))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("canvas %s: scene %s is not saved", name, scene)
+	}
+	if err != nil {
+		return fmt.Errorf("canvas %s: %w", name, err)
+	}
+	return c.Loop.Post(painter.OperationList(ops))
+}
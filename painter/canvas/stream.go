@@ -0,0 +1,62 @@
+package canvas
+
+import (
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+)
+
+// StreamHandler конструює обробник, який транслює кадри полотна як потік multipart/x-mixed-replace (MJPEG).
+// Такий потік можна переглядати у браузері через тег <img>. Формат кожного кадру задається параметром
+// format=jpeg|png|bmp (ті самі формати, що й у SnapshotHandler; за замовчуванням jpeg),
+// номер кадру передається у заголовку частини X-Frame-Number.
+func StreamHandler(c *Canvas) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "jpeg"
+		}
+		enc, ok := encoders[format]
+		if !ok {
+			http.Error(rw, fmt.Sprintf("unsupported format: %s", format), http.StatusBadRequest)
+			return
+		}
+		flusher, ok := rw.(http.Flusher)
+		if !ok {
+			http.Error(rw, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		frames, unsubscribe := c.Subscribe()
+		defer unsubscribe()
+
+		mw := multipart.NewWriter(rw)
+		rw.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mw.Boundary())
+		rw.Header().Set("Cache-Control", "no-cache")
+		rw.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case f := <-frames:
+				part, err := mw.CreatePart(textproto.MIMEHeader{
+					"Content-Type":   {enc.contentType},
+					"X-Frame-Number": {strconv.FormatUint(f.Number, 10)},
+				})
+				if err == nil {
+					err = enc.encode(part, f.Image)
+				}
+				if err != nil {
+					log.Printf("Stream of canvas %s stopped: %s", c.Name, err)
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package painter
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrDropped повертається з Future.Wait, якщо операцію відкинуто політикою OverflowDropOldest.
+var ErrDropped = errors.New("operation was dropped from a full painter loop queue")
+
+// ErrSuperseded повертається з Future.Wait, якщо операція вирішила не малювати, бо новіша операція
+// зробила її непотрібною (див. Superseder).
+var ErrSuperseded = errors.New("operation was superseded by a newer one")
+
+// Superseder реалізують операції, які під час виконання можуть пропустити малювання, бо їх замінила новіша
+// операція (наприклад, скрипт, поставлений у чергу раніше за reset). Superseded викликається у горутині циклу
+// подій одразу після Do і повідомляє, чи було пропущено саме це виконання.
+type Superseder interface {
+	Superseded() bool
+}
+
+// Future дозволяє дочекатися виконання операції, обгорнутої методом Track.
+type Future struct {
+	done    chan struct{}
+	err     error
+	stopped <-chan struct{}
+}
+
+// Track обгортає op операцією, виконання якої можна дочекатися через повернений Future.
+// Обгорнуту операцію потрібно додати в чергу цього циклу (Post, PostUrgent або Reservation).
+// Future завершується, коли op виконано, а якщо op підготувала кадр — коли цей кадр передано у Receiver.Update.
+func (l *Loop) Track(op Operation) (Operation, *Future) {
+	f := &Future{done: make(chan struct{}), stopped: l.stopped}
+	return &trackedOp{Operation: op, future: f}, f
+}
+
+// PostAndWait додає op у чергу та чекає, доки її буде виконано, а підготовлений нею кадр передано у Receiver.Update.
+// На відміну від Post, ctx обмежує як очікування місця в черзі (див. Reserve), так і очікування виконання.
+// Якщо цикл зупиниться раніше, ніж операцію буде виконано, повертає ErrStopped.
+func (l *Loop) PostAndWait(ctx context.Context, op Operation) error {
+	r, err := l.Reserve(ctx)
+	if err != nil {
+		return err
+	}
+	op, f := l.Track(op)
+	if err := r.Post(op); err != nil {
+		return err
+	}
+	return f.Wait(ctx)
+}
+
+// Done повертає канал, який закривається, коли операцію виконано, відкинуто з черги або цикл зупинився
+// раніше, ніж операцію виконано чи підготовлений нею кадр передано у Receiver.Update.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait чекає, доки операцію буде виконано, і повертає nil, ErrDropped, ErrSuperseded, ErrStopped, якщо цикл
+// зупинився раніше, або помилку ctx, якщо ctx скасовано раніше.
+func (f *Future) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-f.stopped:
+		// Операції, виконані до зупинки, завершують Future раніше, ніж закривається stopped.
+		select {
+		case <-f.done:
+			return f.err
+		default:
+			return ErrStopped
+		}
+	}
+}
+
+// resolve завершує f з помилкою err. Викликається не більше одного разу; nil f ігнорується.
+func (f *Future) resolve(err error) {
+	if f == nil {
+		return
+	}
+	f.err = err
+	close(f.done)
+}
+
+// trackedOp — операція Track, про виконання якої цикл подій повідомляє через future.
+type trackedOp struct {
+	Operation
+	future *Future
+}
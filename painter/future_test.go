@@ -0,0 +1,146 @@
+package painter
+
+import (
+	"context"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/shiny/screen"
+)
+
+func TestPostAndWaitWaitsForUpdate(t *testing.T) {
+	receiver := &frameReceiver{frames: make(chan *image.RGBA, 4)}
+	loop := &Loop{Receiver: receiver, MaxFPS: 10}
+	loop.Start(context.Background(), HeadlessScreen{})
+	defer loop.StopAndWait()
+
+	require.NoError(t, loop.PostAndWait(context.Background(), UpdateOp))
+	assert.Len(t, receiver.frames, 1)
+
+	start := time.Now()
+	require.NoError(t, loop.PostAndWait(context.Background(), UpdateOp))
+	assert.Len(t, receiver.frames, 2, "the frame is presented before PostAndWait returns")
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond, "the frame waits for the MaxFPS interval")
+
+	done := false
+	require.NoError(t, loop.PostAndWait(context.Background(), OperationFunc(func(screen.Texture) { done = true })))
+	assert.True(t, done)
+	assert.Len(t, receiver.frames, 2)
+}
+
+func TestPostAndWaitContext(t *testing.T) {
+	loop := &Loop{Receiver: &frameReceiver{frames: make(chan *image.RGBA, 1)}}
+	loop.Start(context.Background(), HeadlessScreen{})
+	defer loop.StopAndWait()
+
+	block := make(chan struct{})
+	defer close(block)
+	require.NoError(t, loop.Post(OperationFunc(func(screen.Texture) { <-block })))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, loop.PostAndWait(ctx, UpdateOp), context.DeadlineExceeded)
+}
+
+func TestPostAndWaitLoopStopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	loop := &Loop{Receiver: &frameReceiver{frames: make(chan *image.RGBA, 1)}}
+	loop.Start(ctx, HeadlessScreen{})
+
+	block := make(chan struct{})
+	require.NoError(t, loop.Post(OperationFunc(func(screen.Texture) { <-block })))
+	op, future := loop.Track(UpdateOp)
+	require.NoError(t, loop.Post(op))
+
+	cancel()
+	close(block)
+	assert.ErrorIs(t, future.Wait(context.Background()), ErrStopped)
+	assert.ErrorIs(t, loop.PostAndWait(context.Background(), UpdateOp), ErrStopped)
+}
+
+func TestTrackDroppedOperation(t *testing.T) {
+	l := newBoundedLoop(1, OverflowDropOldest)
+	op, future := l.Track(namedOp("a"))
+	require.NoError(t, l.Post(op))
+	require.NoError(t, l.Post(namedOp("b")))
+
+	assert.ErrorIs(t, future.Wait(context.Background()), ErrDropped)
+}
+
+// supersededOp — операція, яку завжди замінила новіша операція.
+type supersededOp struct{}
+
+func (supersededOp) Do(screen.Texture) bool { return false }
+func (supersededOp) Superseded() bool       { return true }
+
+func TestPostAndWaitSuperseded(t *testing.T) {
+	loop := &Loop{Receiver: &frameReceiver{frames: make(chan *image.RGBA, 1)}}
+	loop.Start(context.Background(), HeadlessScreen{})
+	defer loop.StopAndWait()
+
+	assert.ErrorIs(t, loop.PostAndWait(context.Background(), supersededOp{}), ErrSuperseded)
+}
+
+func TestFutureDoneAfterStopNow(t *testing.T) {
+	loop := &Loop{Receiver: &frameReceiver{frames: make(chan *image.RGBA, 4)}, MaxFPS: 1}
+	loop.Start(context.Background(), HeadlessScreen{})
+
+	require.NoError(t, loop.PostAndWait(context.Background(), UpdateOp))
+	// Наступний кадр чекає на інтервал MaxFPS.
+	pending, pendingFuture := loop.Track(UpdateOp)
+	require.NoError(t, loop.Post(pending))
+	require.NoError(t, loop.PostAndWait(context.Background(), OperationFunc(func(screen.Texture) {})))
+
+	started, block := make(chan struct{}), make(chan struct{})
+	require.NoError(t, loop.Post(OperationFunc(func(screen.Texture) {
+		close(started)
+		<-block
+	})))
+	<-started
+	queued, queuedFuture := loop.Track(UpdateOp)
+	require.NoError(t, loop.Post(queued))
+
+	stopped := make(chan struct{})
+	go func() {
+		loop.StopNow()
+		close(stopped)
+	}()
+	select {
+	case <-queuedFuture.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the future of a discarded operation is not resolved")
+	}
+	close(block)
+	<-stopped
+
+	assert.ErrorIs(t, queuedFuture.err, ErrStopped)
+	select {
+	case <-pendingFuture.Done():
+		assert.NoError(t, pendingFuture.err, "StopNow presents the ready frame")
+	case <-time.After(time.Second):
+		t.Fatal("the future is not resolved after StopNow")
+	}
+}
+
+func TestFutureDoneAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	loop := &Loop{Receiver: &frameReceiver{frames: make(chan *image.RGBA, 4)}, MaxFPS: 1}
+	loop.Start(ctx, HeadlessScreen{})
+
+	require.NoError(t, loop.PostAndWait(context.Background(), UpdateOp))
+	// Наступний кадр чекає на інтервал MaxFPS і відкидається під час скасування контексту.
+	op, future := loop.Track(UpdateOp)
+	require.NoError(t, loop.Post(op))
+	require.NoError(t, loop.PostAndWait(context.Background(), OperationFunc(func(screen.Texture) {})))
+
+	cancel()
+	select {
+	case <-future.Done():
+		assert.ErrorIs(t, future.err, ErrStopped)
+	case <-time.After(time.Second):
+		t.Fatal("the future of a dropped frame is not resolved")
+	}
+}
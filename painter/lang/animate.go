@@ -0,0 +1,141 @@
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"time"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+)
+
+// easings — функції пом'якшення анімації: перетворюють частку часу (від 0 до 1) у частку шляху.
+var easings = map[string]func(float64) float64{
+	"linear":      func(x float64) float64 { return x },
+	"ease-in":     func(x float64) float64 { return x * x * x },
+	"ease-out":    func(x float64) float64 { return 1 - math.Pow(1-x, 3) },
+	"ease-in-out": easeInOut,
+}
+
+func easeInOut(x float64) float64 {
+	if x < 0.5 {
+		return 4 * x * x * x
+	}
+	return 1 - math.Pow(-2*x+2, 3)/2
+}
+
+// animation переміщує об'єкт сцени так, щоб його точка прив'язки рівномірно (з урахуванням ease)
+// пройшла шлях path за час duration.
+type animation struct {
+	id       string
+	path     image.Point
+	start    time.Time
+	duration time.Duration
+	ease     func(float64) float64
+	applied  image.Point // Частина шляху, на яку об'єкт уже зміщено.
+}
+
+// Animating повідомляє, чи є у сцені незавершені анімації. Після виконання скрипта з командою animate
+// потрібно передати парсер у painter.Loop.Animate, щоб цикл подій малював кадри анімації.
+func (p *Parser) Animating() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.animations) > 0
+}
+
+// Frame реалізує painter.Animator: зміщує об'єкти анімацій у положення на момент t
+// та повертає операції для малювання поточного стану сцени.
+func (p *Parser) Frame(t time.Time) (painter.Operation, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.animations) == 0 {
+		return nil, false
+	}
+	p.advance(t)
+	ops := append(p.scene.Operations(), painter.UpdateOp)
+	return painter.OperationList(ops), len(p.animations) > 0
+}
+
+// advance застосовує до сцени зміщення анімацій на момент t та видаляє завершені анімації.
+// Анімація об'єкта, якого вже немає у сцені, скасовується.
+func (p *Parser) advance(t time.Time) {
+	active := p.animations[:0]
+	for _, a := range p.animations {
+		progress := 1.0
+		if a.duration > 0 {
+			progress = math.Max(0, math.Min(1, float64(t.Sub(a.start))/float64(a.duration)))
+		}
+		k := a.ease(progress)
+		target := image.Pt(int(math.Round(float64(a.path.X)*k)), int(math.Round(float64(a.path.Y)*k)))
+		delta := target.Sub(a.applied)
+		if !p.scene.Move(a.id, delta.X, delta.Y) {
+			continue
+		}
+		a.applied = target
+		if progress < 1 {
+			active = append(active, a)
+		}
+	}
+	p.animations = active
+}
+
+// now повертає поточний час парсера.
+func (p *Parser) now() time.Time {
+	if p.Clock != nil {
+		return p.Clock()
+	}
+	return time.Now()
+}
+
+// animate виконує команду animate <id> to <x> <y> over <тривалість> [ease]: запускає переміщення об'єкта id
+// у точку (x, y). Нова анімація того самого об'єкта замінює попередню.
+func (p *Parser) animate(args []string) error {
+	if len(args) < 6 || len(args) > 7 || args[1] != "to" || args[4] != "over" {
+		return errors.New("animate expects an object id, a target point and a duration")
+	}
+	id := args[0]
+	from, ok := p.scene.Anchor(id)
+	if !ok {
+		return atToken(id, fmt.Errorf("unknown object: %s", id))
+	}
+	to, err := p.coords("animate", args[2:4], 2)
+	if err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(args[5])
+	if err != nil || duration < 0 {
+		return atToken(args[5], fmt.Errorf("invalid duration: %s", args[5]))
+	}
+	ease := easings["linear"]
+	if len(args) == 7 {
+		if ease, ok = easings[args[6]]; !ok {
+			return atToken(args[6], fmt.Errorf("unknown easing: %s", args[6]))
+		}
+	}
+
+	p.cancel(id)
+	p.animations = append(p.animations, animation{
+		id:       id,
+		path:     image.Pt(to[0], to[1]).Sub(from),
+		start:    p.now(),
+		duration: duration,
+		ease:     ease,
+	})
+	return nil
+}
+
+// cancel зупиняє анімацію об'єкта id (або всі анімації, якщо id порожній), залишаючи об'єкт там, де він є.
+// Повертає false, якщо такої анімації не було.
+func (p *Parser) cancel(id string) bool {
+	active := p.animations[:0]
+	for _, a := range p.animations {
+		if id != "" && a.id != id {
+			active = append(active, a)
+		}
+	}
+	found := len(active) < len(p.animations)
+	p.animations = active
+	return found
+}
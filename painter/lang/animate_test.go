@@ -0,0 +1,115 @@
+package lang
+
+import (
+	"context"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// frameFigures повертає координати T-образних фігур кадру анімації на момент t.
+func frameFigures(t *testing.T, p *Parser, at time.Time) ([][2]int, bool) {
+	op, active := p.Frame(at)
+	require.NotNil(t, op)
+	return figuresOf(op.(painter.OperationList)), active
+}
+
+func TestParseAnimate(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	p := &Parser{Clock: func() time.Time { return now }}
+
+	_, err := p.Parse(strings.NewReader("figure 0.1 0.1 id=a\nfigure 0.1 0.5 id=b\nanimate a to 0.5 0.1 over 2s\nanimate b to 0.1 0.9 over 4s ease-in"))
+	require.NoError(t, err)
+	assert.True(t, p.Animating())
+
+	figures, active := frameFigures(t, p, start.Add(time.Second))
+	assert.True(t, active)
+	assert.Equal(t, [][2]int{{240, 80}, {80, 405}}, figures, "linear a is half way, ease-in b is 1/64 of the way")
+
+	figures, active = frameFigures(t, p, start.Add(2*time.Second))
+	assert.True(t, active)
+	assert.Equal(t, [][2]int{{400, 80}, {80, 440}}, figures)
+
+	figures, active = frameFigures(t, p, start.Add(5*time.Second))
+	assert.False(t, active)
+	assert.Equal(t, [][2]int{{400, 80}, {80, 720}}, figures)
+	assert.False(t, p.Animating())
+
+	op, active := p.Frame(start.Add(6 * time.Second))
+	assert.Nil(t, op)
+	assert.False(t, active)
+}
+
+func TestParseAnimateCancelAndReplace(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	p := &Parser{Clock: func() time.Time { return now }}
+
+	_, err := p.Parse(strings.NewReader("figure 0 0 id=a\nanimate a to 0.5 0 over 1s"))
+	require.NoError(t, err)
+
+	now = start.Add(500 * time.Millisecond)
+	ops, err := p.Parse(strings.NewReader("cancel a"))
+	require.NoError(t, err)
+	assert.Equal(t, [][2]int{{200, 0}}, figuresOf(ops), "scripts see the animation progress")
+	assert.False(t, p.Animating())
+
+	_, err = p.Parse(strings.NewReader("cancel a"))
+	assert.ErrorContains(t, err, "no animation for object: a")
+
+	_, err = p.Parse(strings.NewReader("animate a to 0.5 0 over 1s\nanimate a to 0 0.5 over 1s ease-out"))
+	require.NoError(t, err)
+	figures, _ := frameFigures(t, p, now.Add(time.Second))
+	assert.Equal(t, [][2]int{{0, 400}}, figures, "the last animation of an object replaces the previous one")
+
+	_, err = p.Parse(strings.NewReader("animate a to 0.5 0.5 over 1s\nfigure 0 0 id=a\nundo"))
+	require.NoError(t, err)
+	assert.False(t, p.Animating(), "undo stops animations")
+}
+
+func TestParseAnimateErrors(t *testing.T) {
+	tests := []struct {
+		script string
+		err    string
+	}{
+		{script: "animate a to 0.5 0.5 over 1s", err: "unknown object: a"},
+		{script: "figure 0 0 id=a\nanimate a 0.5 0.5 over 1s", err: "animate expects an object id, a target point and a duration"},
+		{script: "figure 0 0 id=a\nanimate a to 0.5 0.5 over soon", err: "invalid duration: soon"},
+		{script: "figure 0 0 id=a\nanimate a to 0.5 0.5 over 1s bounce", err: "unknown easing: bounce"},
+		{script: "cancel a b", err: "cancel expects at most one object id"},
+	}
+	for _, tc := range tests {
+		_, err := (&Parser{}).Parse(strings.NewReader(tc.script))
+		assert.ErrorContains(t, err, tc.err, tc.script)
+	}
+
+	p := &Parser{}
+	_, err := p.Parse(strings.NewReader("figure 0 0 id=a\nanimate a to 0.5 0.5 over 1s\nstar"))
+	require.Error(t, err)
+	assert.False(t, p.Animating(), "animations of a failed script are discarded")
+}
+
+func TestHttpHandlerAnimate(t *testing.T) {
+	rec := &frameRecorder{colors: make(chan color.RGBA, 64)}
+	loop := &painter.Loop{Receiver: rec, FrameRate: 100}
+	loop.Start(context.Background(), painter.HeadlessScreen{})
+	defer loop.StopAndWait()
+	p := &Parser{}
+
+	resp := httptest.NewRecorder()
+	HttpHandler(loop, p).ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("fill white\nfigure 0.1 0.1 id=a\nanimate a to 0.5 0.5 over 50ms\nupdate")))
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	require.Eventually(t, func() bool { return !p.Animating() }, time.Second, 5*time.Millisecond)
+	ops, err := p.Parse(strings.NewReader("update"))
+	require.NoError(t, err)
+	assert.Equal(t, [][2]int{{400, 400}}, figuresOf(ops))
+}
@@ -0,0 +1,258 @@
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// maxSteps обмежує кількість інструкцій, які може виконати один скрипт, щоб цикли не блокували парсер.
+const maxSteps = 100000
+
+// statement — окрема інструкція скрипта з її розташуванням. Інструкції repeat, for та def мають тіло у фігурних дужках.
+type statement struct {
+	text   string
+	line   int
+	column int // Кількість символів рядка перед інструкцією.
+	body   []statement
+}
+
+// errorAt доповнює помилку err розташуванням інструкції.
+func (st statement) errorAt(err error) *ParseError {
+	pe := newParseError(st.line, st.text, err)
+	pe.Column += st.column
+	return pe
+}
+
+// command повертає назву команди інструкції.
+func (st statement) command() string {
+	fields, _ := splitFields(st.text)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// hasBlock перевіряє, чи повинна інструкція мати тіло у фігурних дужках.
+func (st statement) hasBlock() bool {
+	switch st.command() {
+	case "repeat", "for", "def":
+		return true
+	}
+	return false
+}
+
+// buildBlocks групує інструкції між "{" та "}" у тіла інструкцій, що їм передують.
+func buildBlocks(stmts []statement) ([]statement, ParseErrors) {
+	type frame struct {
+		header statement
+		body   []statement
+	}
+	var (
+		errs  ParseErrors
+		stack = []frame{{}}
+	)
+
+	for i := 0; i < len(stmts); i++ {
+		st := stmts[i]
+		top := &stack[len(stack)-1]
+		switch {
+		case st.text == "{":
+			errs = append(errs, st.errorAt(errors.New("unexpected {")))
+		case st.text == "}":
+			if len(stack) == 1 {
+				errs = append(errs, st.errorAt(errors.New("unexpected }")))
+				continue
+			}
+			header := top.header
+			header.body = top.body
+			stack = stack[:len(stack)-1]
+			parent := &stack[len(stack)-1]
+			parent.body = append(parent.body, header)
+		case st.hasBlock():
+			if i+1 < len(stmts) && stmts[i+1].text == "{" {
+				i++
+				stack = append(stack, frame{header: st, body: []statement{}})
+				continue
+			}
+			errs = append(errs, st.errorAt(fmt.Errorf("%s expects a block in { }", st.command())))
+		default:
+			top.body = append(top.body, st)
+		}
+	}
+
+	for len(stack) > 1 {
+		errs = append(errs, stack[len(stack)-1].header.errorAt(errors.New("missing }")))
+		stack = stack[:len(stack)-1]
+	}
+	return stack[0].body, errs
+}
+
+// exec виконує інструкції по черзі, додаючи помилки до errs. Повертає false, якщо виконання треба перервати.
+func (p *Parser) exec(stmts []statement, errs *ParseErrors) bool {
+	for _, st := range stmts {
+		if !p.step(st, errs) {
+			return false
+		}
+
+		var err error
+		switch st.command() {
+		case "let":
+			err = p.let(st.text)
+		case "repeat":
+			err = p.repeat(st, errs)
+		case "for":
+			err = p.loop(st, errs)
+		case "def":
+			err = p.define(st)
+		case "use":
+			err = p.use(st, errs)
+		default:
+			err = p.parse(st.text)
+		}
+		if errors.Is(err, errAborted) {
+			return false
+		}
+		if err != nil {
+			*errs = append(*errs, st.errorAt(err))
+		}
+	}
+	return true
+}
+
+// step враховує ще один крок виконання скрипта (інструкцію чи ітерацію циклу).
+// Повертає false і додає помилку, якщо скрипт перевищив maxSteps.
+func (p *Parser) step(st statement, errs *ParseErrors) bool {
+	p.steps++
+	if p.steps > maxSteps {
+		*errs = append(*errs, st.errorAt(fmt.Errorf("script exceeds the limit of %d steps", maxSteps)))
+		return false
+	}
+	return true
+}
+
+// errAborted повідомляє, що виконання скрипта перервано, а причину вже додано до списку помилок.
+var errAborted = errors.New("script aborted")
+
+// let виконує інструкцію let <змінна> = <вираз>.
+func (p *Parser) let(text string) error {
+	rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), "let"))
+	name, expr, ok := strings.Cut(rest, "=")
+	name, expr = strings.TrimSpace(name), strings.TrimSpace(expr)
+	if !ok || expr == "" {
+		return errors.New("let expects a variable and an expression")
+	}
+	if !validVar(name) {
+		return atToken(name, fmt.Errorf("invalid variable name: %q", name))
+	}
+	v, err := eval(expr, p.vars)
+	if err != nil {
+		return atToken(expr, err)
+	}
+	if p.vars == nil {
+		p.vars = make(map[string]float64)
+	}
+	p.vars[name] = v
+	return nil
+}
+
+// repeat виконує тіло інструкції repeat <кількість> { ... }. Після першої ітерації з помилками цикл зупиняється.
+func (p *Parser) repeat(st statement, errs *ParseErrors) error {
+	fields, _ := splitFields(st.text)
+	if len(fields) != 2 {
+		return errors.New("repeat expects a number of iterations")
+	}
+	n, err := p.number(fields[1])
+	if err != nil {
+		return err
+	}
+	if n < 0 || n != math.Trunc(n) {
+		return atToken(fields[1], fmt.Errorf("invalid number of iterations: %v", n))
+	}
+
+	// Кожна ітерація малює власні фігури, навіть якщо команда без id заміняє попередню фігуру (див. put).
+	p.loops++
+	defer func() { p.loops-- }()
+
+	before := len(*errs)
+	for i := 0; i < int(n) && len(*errs) == before; i++ {
+		if i > 0 && !p.step(st, errs) || !p.exec(st.body, errs) {
+			return errAborted
+		}
+	}
+	return nil
+}
+
+// loop виконує тіло інструкції for <змінна> in <від>..<до> { ... } для значень від, від+1, ..., менших за до.
+// Після циклу змінна повертає попереднє значення.
+func (p *Parser) loop(st statement, errs *ParseErrors) error {
+	fields, _ := splitFields(st.text)
+	if len(fields) < 4 || fields[2] != "in" {
+		return errors.New("for expects a variable and a range")
+	}
+	name := fields[1]
+	if !validVar(name) {
+		return atToken(name, fmt.Errorf("invalid variable name: %q", name))
+	}
+	from, to, ok := strings.Cut(strings.Join(fields[3:], " "), "..")
+	if !ok {
+		return atToken(fields[3], errors.New("range must look like <from>..<to>"))
+	}
+	start, err := eval(strings.TrimSpace(from), p.vars)
+	if err != nil {
+		return atToken(fields[3], err)
+	}
+	end, err := eval(strings.TrimSpace(to), p.vars)
+	if err != nil {
+		return atToken(fields[len(fields)-1], err)
+	}
+
+	if p.vars == nil {
+		p.vars = make(map[string]float64)
+	}
+	prev, defined := p.vars[name]
+	defer func() {
+		if defined {
+			p.vars[name] = prev
+		} else {
+			delete(p.vars, name)
+		}
+	}()
+
+	p.loops++
+	defer func() { p.loops-- }()
+
+	before := len(*errs)
+	for v := start; v < end && len(*errs) == before; v++ {
+		p.vars[name] = v
+		if v > start && !p.step(st, errs) || !p.exec(st.body, errs) {
+			return errAborted
+		}
+	}
+	return nil
+}
+
+// number обчислює числовий аргумент команди, який може бути виразом зі змінними.
+func (p *Parser) number(arg string) (float64, error) {
+	v, err := eval(arg, p.vars)
+	if err != nil {
+		return 0, atToken(arg, err)
+	}
+	return v, nil
+}
+
+// blockDepth повертає, на скільки рядок скрипта line змінює вкладеність блоків { }.
+func blockDepth(line string) int {
+	commands, _ := splitCommands(line)
+	depth := 0
+	for _, c := range commands {
+		switch c {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+	}
+	return depth
+}
@@ -0,0 +1,84 @@
+package lang
+
+import (
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+// ParseColor розбирає колір, заданий у скрипті. Підтримуються формати:
+//   - шістнадцятковий: #rgb, #rrggbb або #rrggbbaa;
+//   - назви кольорів CSS, наприклад "white" чи "tomato";
+//   - функціональний запис rgb(r, g, b) або rgba(r, g, b, a), де r, g, b у межах 0..255, а a у межах 0..1.
+func ParseColor(s string) (color.Color, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	switch {
+	case strings.HasPrefix(s, "#"):
+		return parseHexColor(s)
+	case strings.HasPrefix(s, "rgb"):
+		return parseFuncColor(s)
+	}
+
+	if c, ok := colornames.Map[s]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("unknown color: %s", s)
+}
+
+// parseHexColor розбирає колір у форматі #rgb, #rrggbb або #rrggbbaa.
+func parseHexColor(s string) (color.Color, error) {
+	digits := s[1:]
+	if len(digits) == 3 {
+		digits = string([]byte{digits[0], digits[0], digits[1], digits[1], digits[2], digits[2]})
+	}
+	if len(digits) == 6 {
+		digits += "ff"
+	}
+
+	b, err := hex.DecodeString(digits)
+	if err != nil || len(b) != 4 {
+		return nil, fmt.Errorf("invalid hex color: %s", s)
+	}
+
+	// color.RGBA зберігає компоненти, помножені на альфа-канал.
+	return color.NRGBA{R: b[0], G: b[1], B: b[2], A: b[3]}, nil
+}
+
+// parseFuncColor розбирає колір у форматі rgb(r, g, b) або rgba(r, g, b, a).
+func parseFuncColor(s string) (color.Color, error) {
+	open, end := strings.IndexByte(s, '('), len(s)-1
+	if open < 0 || s[end] != ')' {
+		return nil, fmt.Errorf("invalid color: %s", s)
+	}
+
+	name := s[:open]
+	parts := strings.Split(s[open+1:end], ",")
+	if (name != "rgb" || len(parts) != 3) && (name != "rgba" || len(parts) != 4) {
+		return nil, fmt.Errorf("invalid color: %s", s)
+	}
+
+	var channels [3]uint8
+	for i := range channels {
+		v, err := strconv.ParseUint(strings.TrimSpace(parts[i]), 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid color channel in %s: %s", s, parts[i])
+		}
+		channels[i] = uint8(v)
+	}
+
+	alpha := uint8(255)
+	if len(parts) == 4 {
+		a, err := strconv.ParseFloat(strings.TrimSpace(parts[3]), 64)
+		if err != nil || a < 0 || a > 1 {
+			return nil, fmt.Errorf("invalid alpha in %s: %s", s, parts[3])
+		}
+		alpha = uint8(a*255 + 0.5)
+	}
+
+	return color.NRGBA{R: channels[0], G: channels[1], B: channels[2], A: alpha}, nil
+}
@@ -0,0 +1,42 @@
+package lang
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in     string
+		expect color.Color
+	}{
+		{in: "#fff", expect: color.NRGBA{R: 255, G: 255, B: 255, A: 255}},
+		{in: "#102030", expect: color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 255}},
+		{in: "#10203040", expect: color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0x40}},
+		{in: "#ABCDEF", expect: color.NRGBA{R: 0xab, G: 0xcd, B: 0xef, A: 255}},
+		{in: "tomato", expect: color.RGBA{R: 0xff, G: 0x63, B: 0x47, A: 0xff}},
+		{in: "White", expect: color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+		{in: "rgb(1,2,3)", expect: color.NRGBA{R: 1, G: 2, B: 3, A: 255}},
+		{in: "rgba(1, 2, 3, 0.5)", expect: color.NRGBA{R: 1, G: 2, B: 3, A: 128}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			c, err := ParseColor(tc.in)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, c)
+		})
+	}
+}
+
+func TestParseColorErrors(t *testing.T) {
+	for _, in := range []string{"", "#12", "#gggggg", "nocolor", "rgb(1, 2)", "rgb(256, 0, 0)", "rgba(0, 0, 0, 2)", "hsl(0, 0, 0)"} {
+		t.Run(in, func(t *testing.T) {
+			_, err := ParseColor(in)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// signatures описує очікуваний формат кожної команди скрипта для повідомлень про помилки.
+var signatures = map[string]string{
+	"fill":       "fill <color>",
+	"white":      "white",
+	"green":      "green",
+	"update":     "update",
+	"bgrect":     "bgrect <x1> <y1> <x2> <y2> [color] [blend=<mode>] [opacity=<0..1>] [id=<id>]",
+	"figure":     "figure <x> <y> [color] [blend=<mode>] [opacity=<0..1>] [id=<id>]",
+	"move":       "move [id] <dx> <dy>",
+	"delete":     "delete <id>",
+	"circle":     "circle <x> <y> <r> [width] [color] [blend=<mode>] [opacity=<0..1>] [id=<id>]",
+	"ellipse":    "ellipse <x> <y> <rx> <ry> [width] [color] [blend=<mode>] [opacity=<0..1>] [id=<id>]",
+	"arc":        "arc <x> <y> <r> <start> <end> [width] [color] [blend=<mode>] [opacity=<0..1>] [id=<id>]",
+	"undo":       "undo",
+	"redo":       "redo",
+	"checkpoint": "checkpoint <name>",
+	"restore":    "restore <name>",
+	"save":       "save <name>",
+	"load":       "load <name>",
+	"reset":      "reset",
+	"let":        "let <name> = <expression>",
+	"repeat":     "repeat <count> { ... }",
+	"for":        "for <name> in <from>..<to> { ... }",
+	"def":        "def <name> { ... }",
+	"use":        "use <name> <dx> <dy> [id=<id>]",
+	"animate":    "animate <id> to <x> <y> over <duration> [linear|ease-in|ease-out|ease-in-out]",
+	"cancel":     "cancel [id]",
+}
+
+// ParseError описує помилку в одному рядку скрипта.
+type ParseError struct {
+	Line     int    // Номер рядка, починаючи з 1. Для JSON скриптів — номер команди у списку ops.
+	Column   int    // Номер символу в рядку, з якого починається Token, або команда, якщо Token порожній.
+	Token    string // Аргумент, що спричинив помилку; порожній, якщо помилка стосується команди загалом.
+	Command  string // Назва команди.
+	Expected string // Очікуваний формат команди, якщо вона відома.
+	Err      error
+}
+
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "line %d", e.Line)
+	if e.Column > 0 {
+		fmt.Fprintf(&b, ", column %d", e.Column)
+	}
+	fmt.Fprintf(&b, ": %s", e.Err)
+	if e.Token != "" {
+		fmt.Fprintf(&b, " (at %q)", e.Token)
+	}
+	if e.Expected != "" {
+		fmt.Fprintf(&b, "; expected: %s", e.Expected)
+	}
+	return b.String()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseErrors — усі помилки, знайдені у скрипті.
+type ParseErrors []*ParseError
+
+func (errs ParseErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (errs ParseErrors) Unwrap() []error {
+	res := make([]error, len(errs))
+	for i, e := range errs {
+		res[i] = e
+	}
+	return res
+}
+
+// tokenError — помилка, спричинена конкретним аргументом команди.
+type tokenError struct {
+	token string
+	err   error
+}
+
+func (e *tokenError) Error() string {
+	return e.err.Error()
+}
+
+func (e *tokenError) Unwrap() error {
+	return e.err
+}
+
+// atToken позначає, що помилку err спричинив аргумент token.
+func atToken(token string, err error) error {
+	return &tokenError{token: token, err: err}
+}
+
+// newParseError доповнює помилку err, що виникла у рядку line з текстом text, її розташуванням та контекстом команди.
+func newParseError(line int, text string, err error) *ParseError {
+	fields, offsets := splitFields(text)
+	pe := &ParseError{Line: line, Err: err}
+	if len(fields) == 0 {
+		pe.Column = 1
+		return pe
+	}
+
+	pe.Command, pe.Expected = fields[0], signatures[fields[0]]
+	column := offsets[0]
+	var te *tokenError
+	if errors.As(err, &te) {
+		pe.Token, pe.Err = te.token, te.err
+		for i, f := range fields {
+			if f == te.token {
+				column = offsets[i]
+				break
+			}
+		}
+	}
+	pe.Column = utf8.RuneCountInString(text[:column]) + 1
+	return pe
+}
+
+// unknownCommand — мітка помилок, не пов'язаних з відомою командою (див. Parser.ErrorCounts).
+const unknownCommand = "unknown"
+
+// errorCounter рахує помилки скриптів за назвою команди.
+type errorCounter struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+// add враховує кожну помилку err: ParseError, ParseErrors або будь-яку іншу (як unknownCommand).
+func (c *errorCounter) add(err error) {
+	var (
+		errs ParseErrors
+		pe   *ParseError
+	)
+	switch {
+	case errors.As(err, &errs):
+	case errors.As(err, &pe):
+		errs = ParseErrors{pe}
+	default:
+		errs = ParseErrors{{}}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]uint64)
+	}
+	for _, e := range errs {
+		// Назви невідомих команд походять від клієнтів, тож не потрапляють у лічильники окремо.
+		command := e.Command
+		if _, ok := signatures[command]; !ok {
+			command = unknownCommand
+		}
+		c.counts[command]++
+	}
+}
+
+// ErrorCounts повертає кількість помилок у скриптах, відхилених парсером, за назвою команди.
+// Помилки невідомих команд та помилки, не пов'язані з конкретною командою, враховуються як "unknown".
+func (p *Parser) ErrorCounts() map[string]uint64 {
+	p.failures.mu.Lock()
+	defer p.failures.mu.Unlock()
+	return maps.Clone(p.failures.counts)
+}
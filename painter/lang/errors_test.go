@@ -0,0 +1,156 @@
+package lang
+
+import (
+	"context"
+	"encoding/json"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReportsAllErrors(t *testing.T) {
+	p := &Parser{}
+	_, err := p.Parse(strings.NewReader("fill red\nfigure 0.5 abc\nupdate\n  circle 0.1 0.1 0.1 blend=nope\nstar 1 2\nmove 0.1"))
+
+	var errs ParseErrors
+	require.ErrorAs(t, err, &errs)
+	require.Len(t, errs, 4)
+
+	assert.Equal(t, 2, errs[0].Line)
+	assert.Equal(t, 12, errs[0].Column)
+	assert.Equal(t, "abc", errs[0].Token)
+	assert.Equal(t, "figure", errs[0].Command)
+	assert.Equal(t, signatures["figure"], errs[0].Expected)
+	assert.EqualError(t, errs[0].Err, "unknown variable: abc")
+
+	assert.Equal(t, 4, errs[1].Line)
+	assert.Equal(t, 22, errs[1].Column)
+	assert.Equal(t, "blend=nope", errs[1].Token)
+
+	assert.Equal(t, 5, errs[2].Line)
+	assert.Equal(t, 1, errs[2].Column)
+	assert.Equal(t, "star", errs[2].Token)
+	assert.Empty(t, errs[2].Expected)
+
+	assert.Equal(t, 6, errs[3].Line)
+	assert.Equal(t, 1, errs[3].Column, "errors without a token point at the command")
+	assert.Empty(t, errs[3].Token)
+
+	assert.Equal(t, `line 2, column 12: unknown variable: abc (at "abc"); expected: `+signatures["figure"], errs[0].Error())
+}
+
+func TestParseErrorColumnCountsRunes(t *testing.T) {
+	_, err := (&Parser{}).Parse(strings.NewReader("figure id=фігура 0.1 x"))
+
+	var errs ParseErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, 22, errs[0].Column)
+}
+
+func TestParseErrorUnknownOptionIsStable(t *testing.T) {
+	scripts := map[string]string{
+		"figure 0.5 0.5 zeta=1 beta=2 alpha=3":           "alpha=3",
+		"def m { figure 0 0 }\nuse m 0 0 zeta=1 alpha=2": "alpha=2",
+	}
+	for script, token := range scripts {
+		for range 20 {
+			_, err := (&Parser{}).Parse(strings.NewReader(script))
+			var errs ParseErrors
+			require.ErrorAs(t, err, &errs)
+			require.Len(t, errs, 1)
+			require.Equal(t, token, errs[0].Token, script)
+		}
+	}
+}
+
+func TestParserErrorCounts(t *testing.T) {
+	p := &Parser{}
+	_, err := p.Parse(strings.NewReader("fill red\nfigure 0.5 abc\nfigure 1\nstar 1 2"))
+	require.Error(t, err)
+	_, err = p.ParseJSON(strings.NewReader(`{"ops": [{"op": "fill", "color": "nope"}]}`))
+	require.Error(t, err)
+	_, err = p.ParseJSON(strings.NewReader(`not json`))
+	require.Error(t, err)
+	_, err = p.Parse(strings.NewReader("fill red\nupdate"))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]uint64{"figure": 2, "fill": 1, "unknown": 2}, p.ErrorCounts())
+}
+
+func TestHttpHandlerProblem(t *testing.T) {
+	loop := &painter.Loop{Receiver: &frameRecorder{colors: make(chan color.RGBA, 1)}}
+	loop.Start(context.Background(), painter.HeadlessScreen{})
+	defer loop.StopAndWait()
+
+	rec := httptest.NewRecorder()
+	HttpHandler(loop, &Parser{}).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("figure x 0.5\nfill nope")))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+
+	var pr Problem
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&pr))
+	assert.Equal(t, http.StatusBadRequest, pr.Status)
+	assert.Equal(t, []ProblemError{
+		{Line: 1, Column: 8, Token: "x", Command: "figure", Expected: signatures["figure"], Message: "unknown variable: x"},
+		{Line: 2, Column: 6, Token: "nope", Command: "fill", Expected: signatures["fill"], Message: "unknown color: nope"},
+	}, pr.Errors)
+}
+
+func TestParseRejectsExtraArguments(t *testing.T) {
+	for _, comm := range []string{"white", "green", "update", "undo", "redo", "reset"} {
+		_, err := (&Parser{}).Parse(strings.NewReader("fill red\n" + comm + " 0.5"))
+		var errs ParseErrors
+		require.ErrorAs(t, err, &errs, comm)
+		require.Len(t, errs, 1)
+		assert.Equal(t, "0.5", errs[0].Token)
+		assert.Equal(t, signatures[comm], errs[0].Expected)
+		assert.EqualError(t, errs[0].Err, comm+" expects no arguments")
+	}
+}
+
+func TestHistoryHandlerProblem(t *testing.T) {
+	loop := &painter.Loop{Receiver: &frameRecorder{colors: make(chan color.RGBA, 1)}}
+	loop.Start(context.Background(), painter.HeadlessScreen{})
+	defer loop.StopAndWait()
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /checkpoint/{checkpoint}", HistoryHandler(loop, &Parser{}, "checkpoint"))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/checkpoint/1st", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+
+	var pr Problem
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&pr))
+	assert.Equal(t, http.StatusBadRequest, pr.Status)
+	assert.Equal(t, `invalid checkpoint name: "1st"`, pr.Detail)
+}
+
+func TestParseLongLines(t *testing.T) {
+	p := &Parser{}
+	// Рядок довший за стандартний буфер bufio.Scanner (64 КБ).
+	long := "figure 0.5 0.5" + strings.Repeat(" ", 80<<10) + "# centre"
+	ops, err := p.Parse(strings.NewReader("fill red\n" + long + "\nupdate"))
+	require.NoError(t, err)
+	assert.Len(t, ops, 3)
+
+	_, err = p.Parse(strings.NewReader("reset\nfill green\n" + strings.Repeat("#", maxLineSize+1) + "\nupdate"))
+	var errs ParseErrors
+	require.ErrorAs(t, err, &errs)
+	require.Len(t, errs, 1)
+	assert.Equal(t, 3, errs[0].Line)
+	assert.Contains(t, errs[0].Error(), "line is longer than")
+
+	ops, err = p.Parse(strings.NewReader("update"))
+	require.NoError(t, err)
+	require.Len(t, ops, 3, "the script with a too long line is not executed")
+	assert.Equal(t, &painter.FillOp{Color: color.RGBA{R: 0xff, A: 0xff}}, ops[0])
+}
@@ -0,0 +1,190 @@
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// eval обчислює арифметичний вираз s: числа, змінні з vars, операції +, -, *, /, %, унарні + та - і дужки.
+func eval(s string, vars map[string]float64) (float64, error) {
+	// Звичайне число — найчастіший випадок, його розбирає strconv. Він же приймає NaN та Inf,
+	// тож перевірка скінченності нижче стосується і цього випадку.
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		e := &exprParser{src: s, vars: vars}
+		v, err = e.sum()
+		if err == nil && e.peek() != 0 {
+			err = fmt.Errorf("unexpected %q in expression %q", e.src[e.pos:], s)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("expression %q is not a finite number", s)
+	}
+	return v, nil
+}
+
+// exprParser — розбір виразу методом рекурсивного спуску.
+type exprParser struct {
+	src  string
+	pos  int
+	vars map[string]float64
+}
+
+// peek повертає наступний символ виразу, пропускаючи пробіли, або 0 у кінці виразу.
+func (e *exprParser) peek() rune {
+	for e.pos < len(e.src) {
+		r, size := utf8.DecodeRuneInString(e.src[e.pos:])
+		if !unicode.IsSpace(r) {
+			return r
+		}
+		e.pos += size
+	}
+	return 0
+}
+
+// sum = product { ("+" | "-") product }
+func (e *exprParser) sum() (float64, error) {
+	v, err := e.product()
+	for err == nil {
+		op := e.peek()
+		if op != '+' && op != '-' {
+			break
+		}
+		e.pos++
+		var r float64
+		if r, err = e.product(); op == '+' {
+			v += r
+		} else {
+			v -= r
+		}
+	}
+	return v, err
+}
+
+// product = unary { ("*" | "/" | "%") unary }
+func (e *exprParser) product() (float64, error) {
+	v, err := e.unary()
+	for err == nil {
+		op := e.peek()
+		if op != '*' && op != '/' && op != '%' {
+			break
+		}
+		e.pos++
+		var r float64
+		if r, err = e.unary(); err != nil {
+			break
+		}
+		switch {
+		case op == '*':
+			v *= r
+		case r == 0:
+			err = errors.New("division by zero")
+		case op == '/':
+			v /= r
+		default:
+			v = math.Mod(v, r)
+		}
+	}
+	return v, err
+}
+
+// unary = ("+" | "-") unary | primary
+func (e *exprParser) unary() (float64, error) {
+	switch e.peek() {
+	case '-':
+		e.pos++
+		v, err := e.unary()
+		return -v, err
+	case '+':
+		e.pos++
+		return e.unary()
+	}
+	return e.primary()
+}
+
+// primary = число | змінна | "(" sum ")"
+func (e *exprParser) primary() (float64, error) {
+	r := e.peek()
+	start := e.pos
+	switch {
+	case r == '(':
+		e.pos++
+		v, err := e.sum()
+		if err != nil {
+			return 0, err
+		}
+		if e.peek() != ')' {
+			return 0, fmt.Errorf("missing ) in expression %q", e.src)
+		}
+		e.pos++
+		return v, nil
+	case r == '.' || unicode.IsDigit(r):
+		e.scanNumber()
+		v, err := strconv.ParseFloat(e.src[start:e.pos], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", e.src[start:e.pos])
+		}
+		return v, nil
+	case r == '_' || unicode.IsLetter(r):
+		for e.pos < len(e.src) {
+			r, size := utf8.DecodeRuneInString(e.src[e.pos:])
+			if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				break
+			}
+			e.pos += size
+		}
+		name := e.src[start:e.pos]
+		v, ok := e.vars[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown variable: %s", name)
+		}
+		return v, nil
+	case r == 0:
+		return 0, fmt.Errorf("unexpected end of expression %q", e.src)
+	default:
+		return 0, fmt.Errorf("unexpected %q in expression %q", r, e.src)
+	}
+}
+
+// scanNumber пропускає число з необов'язковою експонентою (наприклад, 1.5e-3).
+func (e *exprParser) scanNumber() {
+	digits := func() {
+		for e.pos < len(e.src) && (e.src[e.pos] == '.' || isDigit(e.src[e.pos])) {
+			e.pos++
+		}
+	}
+	digits()
+	if e.pos < len(e.src) && (e.src[e.pos] == 'e' || e.src[e.pos] == 'E') {
+		i := e.pos + 1
+		if i < len(e.src) && (e.src[i] == '+' || e.src[i] == '-') {
+			i++
+		}
+		if i < len(e.src) && isDigit(e.src[i]) {
+			e.pos = i
+			digits()
+		}
+	}
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// validVar перевіряє, що name може бути назвою змінної: починається з літери або "_" і містить лише літери,
+// цифри та "_".
+func validVar(name string) bool {
+	for i, r := range name {
+		if unicode.IsLetter(r) || r == '_' || (i > 0 && unicode.IsDigit(r)) {
+			continue
+		}
+		return false
+	}
+	return name != ""
+}
@@ -0,0 +1,61 @@
+package lang
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEval(t *testing.T) {
+	vars := map[string]float64{"x": 0.25, "step_2": 2}
+	tests := []struct {
+		expr  string
+		value float64
+		err   string
+	}{
+		{expr: "0.5", value: 0.5},
+		{expr: "-1e-3", value: -0.001},
+		{expr: "x+0.05", value: 0.3},
+		{expr: "1+2*3", value: 7},
+		{expr: "(1+2)*3", value: 9},
+		{expr: "( x + 0.25 ) * step_2", value: 1},
+		{expr: "-x*-4", value: 1},
+		{expr: "7%3/2", value: 0.5},
+		{expr: "2e1-x*4", value: 19},
+		{expr: "y", err: "unknown variable: y"},
+		{expr: "1/0", err: "division by zero"},
+		{expr: "1+", err: "unexpected end"},
+		{expr: "(1+2", err: "missing )"},
+		{expr: "1 2", err: "unexpected"},
+		{expr: "red", err: "unknown variable"},
+		{expr: "#fff", err: "unexpected"},
+		{expr: "NaN", err: "not a finite number"},
+		{expr: "Inf", err: "not a finite number"},
+		{expr: "-infinity", err: "not a finite number"},
+		{expr: "1e400", err: "invalid number"},
+	}
+	for _, tc := range tests {
+		v, err := eval(tc.expr, vars)
+		if tc.err != "" {
+			assert.ErrorContains(t, err, tc.err, tc.expr)
+			continue
+		}
+		if assert.NoError(t, err, tc.expr) {
+			assert.InDelta(t, tc.value, v, 1e-9, tc.expr)
+		}
+	}
+}
+
+func TestParseRejectsNonFiniteNumbers(t *testing.T) {
+	scripts := map[string]string{
+		"figure Inf NaN":             "not a finite number",
+		"figure 0.5 0.5 opacity=NaN": "invalid opacity",
+		"let x = Inf":                "not a finite number",
+		"repeat Inf { figure 0 0 }":  "not a finite number",
+	}
+	for script, msg := range scripts {
+		_, err := (&Parser{}).Parse(strings.NewReader(script))
+		assert.ErrorContains(t, err, msg, script)
+	}
+}
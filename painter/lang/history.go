@@ -0,0 +1,57 @@
+package lang
+
+import (
+	"maps"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+)
+
+// historyLimit обмежує кількість кроків, які можна скасувати.
+const historyLimit = 100
+
+// history зберігає попередні стани сцени для команд undo/redo та іменовані контрольні точки.
+type history struct {
+	undo        []painter.Scene
+	redo        []painter.Scene
+	checkpoints map[string]painter.Scene
+}
+
+// record запам'ятовує стан prev як крок, який можна скасувати. Скасовані раніше кроки після цього повернути не можна.
+func (h *history) record(prev painter.Scene) {
+	h.undo = append(h.undo, prev)
+	if len(h.undo) > historyLimit {
+		h.undo = h.undo[len(h.undo)-historyLimit:]
+	}
+	h.redo = nil
+}
+
+// back повертає стан перед останнім кроком, запам'ятовуючи cur для redo.
+func (h *history) back(cur painter.Scene) (painter.Scene, bool) {
+	if len(h.undo) == 0 {
+		return painter.Scene{}, false
+	}
+	prev := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, cur)
+	return prev, true
+}
+
+// forward повертає стан, скасований останнім викликом back, запам'ятовуючи cur для undo.
+func (h *history) forward(cur painter.Scene) (painter.Scene, bool) {
+	if len(h.redo) == 0 {
+		return painter.Scene{}, false
+	}
+	next := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, cur)
+	return next, true
+}
+
+// clone повертає копію історії, щоб відновити її, якщо скрипт завершився з помилкою.
+func (h *history) clone() history {
+	return history{
+		undo:        append([]painter.Scene(nil), h.undo...),
+		redo:        append([]painter.Scene(nil), h.redo...),
+		checkpoints: maps.Clone(h.checkpoints),
+	}
+}
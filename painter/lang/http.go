@@ -1,31 +1,224 @@
 package lang
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/roman-mazur/architecture-lab-3/painter"
+	"golang.org/x/exp/shiny/screen"
 )
 
 // HttpHandler конструює обробник HTTP запитів, який дані з запиту віддає у Parser, а потім відправляє отриманий список
-// операцій у painter.Loop.
+// операцій у painter.Loop. Тіло з Content-Type: application/json розбирається як JSON скрипт (див. Schema).
+// З параметром wait=true відповідь надсилається після того, як кадр скрипта з'явився на екрані.
 func HttpHandler(loop *painter.Loop, p *Parser) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		var in io.Reader = r.Body
-		if r.Method == http.MethodGet {
+		switch {
+		case r.Method == http.MethodGet:
 			in = strings.NewReader(r.URL.Query().Get("cmd"))
+		case IsJSON(r):
+			script, err := jsonToScript(r.Body)
+			if err != nil {
+				p.failures.add(err)
+				log.Printf("Bad JSON script: %s", err)
+				writeProblem(rw, http.StatusBadRequest, err)
+				return
+			}
+			in = strings.NewReader(script)
 		}
 
-		cmds, err := p.Parse(in)
+		serveScript(rw, r, loop, p, in)
+	})
+}
+
+// SchemaHandler конструює обробник HTTP запитів, який віддає JSON Schema формату команд.
+func SchemaHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/schema+json")
+		_, _ = rw.Write(Schema)
+	})
+}
+
+// HistoryHandler конструює обробник HTTP запитів, який виконує команду історії action (undo, redo, checkpoint
+// або restore) так само, як у скрипті, і відправляє оновлений малюнок у painter.Loop.
+// Назва контрольної точки для checkpoint та restore береться з параметра шляху {checkpoint}.
+func HistoryHandler(loop *painter.Loop, p *Parser, action string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		script := action
+		if cp := r.PathValue("checkpoint"); cp != "" {
+			if !validID(cp) {
+				log.Printf("Bad checkpoint name: %q", cp)
+				writeProblem(rw, http.StatusBadRequest, fmt.Errorf("invalid checkpoint name: %q", cp))
+				return
+			}
+			script += " " + cp
+		}
+		if action != "checkpoint" {
+			script += "\nupdate"
+		}
+
+		serveScript(rw, r, loop, p, strings.NewReader(script))
+	})
+}
+
+// retryAfter — значення заголовка Retry-After (у секундах) для відповідей 429, коли черга painter.Loop заповнена.
+const retryAfter = "1"
+
+// serveScript виконує скрипт з in та відправляє отримані операції у painter.Loop.
+// Місце в черзі резервується до виконання скрипта, тож відхилений через заповнену чергу скрипт не змінює сцену
+// і його можна безпечно надіслати повторно.
+// З параметром запиту wait=true відповідь надсилається лише після того, як операції скрипта виконано,
+// а підготовлений ними кадр передано у painter.Receiver (див. painter.Loop.Track). Якщо кадр скрипта пропущено,
+// бо сцену вже скинув новіший скрипт з reset, відповідь має статус 409 Conflict.
+func serveScript(rw http.ResponseWriter, r *http.Request, loop *painter.Loop, p *Parser, in io.Reader) {
+	wait := false
+	if v := r.URL.Query().Get("wait"); v != "" {
+		var err error
+		if wait, err = strconv.ParseBool(v); err != nil {
+			writeProblem(rw, http.StatusBadRequest, fmt.Errorf("invalid wait parameter: %s", v))
+			return
+		}
+	}
+
+	slot, err := loop.Reserve(r.Context())
+	if errors.Is(err, painter.ErrQueueFull) {
+		rw.Header().Set("Retry-After", retryAfter)
+		writeProblem(rw, http.StatusTooManyRequests, err)
+		return
+	}
+	if err != nil {
+		log.Printf("Cannot post operations: %s", err)
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	res, err := p.run(in)
+	if err != nil {
+		slot.Release()
+		log.Printf("Bad script: %s", err)
+		writeProblem(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	future, err := p.post(loop, slot, res)
+	if err != nil {
+		log.Printf("Cannot post operations: %s", err)
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if p.Animating() {
+		loop.Animate(p)
+	}
+	if wait {
+		err := future.Wait(r.Context())
+		if errors.Is(err, painter.ErrSuperseded) {
+			// Сцену вже скинув новіший скрипт, тож кадр цього скрипта не буде показано.
+			writeProblem(rw, http.StatusConflict, err)
+			return
+		}
 		if err != nil {
-			log.Printf("Bad script: %s", err)
-			rw.WriteHeader(http.StatusBadRequest)
+			log.Printf("Operations were not drawn: %s", err)
+			rw.WriteHeader(http.StatusServiceUnavailable)
 			return
 		}
+	}
+	rw.WriteHeader(http.StatusOK)
+}
 
-		loop.Post(painter.OperationList(cmds))
-		rw.WriteHeader(http.StatusOK)
-	})
+// post відправляє операції виконаного скрипта r у зарезервоване місце черги painter.Loop і повертає
+// painter.Future, що завершується після їх виконання.
+// Скрипт, що скидає сцену командою reset, відправляється поза чергою (painter.Loop.PostUrgent),
+// а ще не виконані операції попередніх скриптів p пропускаються, щоб вони не намалювали застарілу сцену поверх нової.
+func (p *Parser) post(loop *painter.Loop, slot *painter.Reservation, r result) (*painter.Future, error) {
+	op, future := loop.Track(&scriptOp{ops: painter.OperationList(r.ops), seq: r.seq, parser: p})
+	if !r.urgent {
+		return future, slot.Post(op)
+	}
+	for {
+		skipped := p.skipped.Load()
+		if skipped >= r.seq || p.skipped.CompareAndSwap(skipped, r.seq) {
+			break
+		}
+	}
+	return future, slot.PostUrgent(op)
+}
+
+// scriptOp — операції скрипта з порядковим номером seq.
+type scriptOp struct {
+	ops        painter.OperationList
+	seq        uint64
+	parser     *Parser
+	superseded bool // Останнє виконання пропущено через новіший скрипт з reset
+}
+
+func (op *scriptOp) Do(t screen.Texture) bool {
+	return op.DoEach(func(o painter.Operation) bool { return o.Do(t) })
+}
+
+// DoEach реалізує painter.Composite, щоб цикл подій рахував час виконання окремих операцій скрипта.
+func (op *scriptOp) DoEach(do func(painter.Operation) bool) bool {
+	op.superseded = op.seq < op.parser.skipped.Load()
+	if op.superseded {
+		return false
+	}
+	return op.ops.DoEach(do)
+}
+
+// Superseded реалізує painter.Superseder.
+func (op *scriptOp) Superseded() bool {
+	return op.superseded
+}
+
+// Problem — опис помилки запиту у форматі RFC 9457 (application/problem+json).
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+	// Errors перелічує всі помилки скрипта, якщо запит відхилено через них.
+	Errors []ProblemError `json:"errors,omitempty"`
+}
+
+// ProblemError — помилка в рядку скрипта (див. ParseError).
+type ProblemError struct {
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Command  string `json:"command,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Message  string `json:"message"`
+}
+
+// writeProblem надсилає відповідь зі статусом status та описом помилки err у форматі Problem.
+func writeProblem(rw http.ResponseWriter, status int, err error) {
+	pr := Problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Detail: err.Error()}
+
+	var errs ParseErrors
+	if errors.As(err, &errs) {
+		pr.Title = "Invalid script"
+		pr.Detail = fmt.Sprintf("script has %d invalid line(s)", len(errs))
+		for _, e := range errs {
+			pr.Errors = append(pr.Errors, ProblemError{
+				Line:     e.Line,
+				Column:   e.Column,
+				Token:    e.Token,
+				Command:  e.Command,
+				Expected: e.Expected,
+				Message:  e.Err.Error(),
+			})
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/problem+json")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(pr); err != nil {
+		log.Printf("Cannot write response: %s", err)
+	}
 }
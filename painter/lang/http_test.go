@@ -0,0 +1,134 @@
+package lang
+
+import (
+	"context"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/shiny/screen"
+)
+
+// serve надсилає скрипт у handler і повертає відповідь.
+func serve(handler http.Handler, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestHttpHandlerPostsResetUrgently(t *testing.T) {
+	recorder := &frameRecorder{colors: make(chan color.RGBA, 8)}
+	loop := &painter.Loop{Receiver: recorder}
+	loop.Start(context.Background(), painter.HeadlessScreen{})
+	handler := HttpHandler(loop, &Parser{})
+
+	block := make(chan struct{})
+	require.NoError(t, loop.Post(painter.OperationFunc(func(screen.Texture) { <-block })))
+	for _, script := range []string{"fill red\nupdate", "fill lime\nupdate", "figure 0.5 0.5\nupdate"} {
+		require.Equal(t, http.StatusOK, serve(handler, "/", script).Code)
+	}
+	require.Equal(t, http.StatusOK, serve(handler, "/", "reset\nfill blue\nupdate").Code)
+	require.Equal(t, http.StatusOK, serve(handler, "/", "fill white\nupdate").Code)
+	close(block)
+	loop.StopAndWait()
+	close(recorder.colors)
+
+	var got []color.RGBA
+	for c := range recorder.colors {
+		got = append(got, c)
+	}
+	assert.Equal(t, []color.RGBA{{B: 0xff, A: 0xff}, {R: 0xff, G: 0xff, B: 0xff, A: 0xff}}, got,
+		"reset is drawn first and scripts queued before it are skipped")
+}
+
+func TestHttpHandlerRejectsWhenQueueIsFull(t *testing.T) {
+	recorder := &frameRecorder{colors: make(chan color.RGBA, 8)}
+	loop := &painter.Loop{Receiver: recorder, Capacity: 1, Overflow: painter.OverflowReject}
+	loop.Start(context.Background(), painter.HeadlessScreen{})
+	p := &Parser{}
+	handler := HttpHandler(loop, p)
+
+	block := make(chan struct{})
+	require.NoError(t, loop.Post(painter.OperationFunc(func(screen.Texture) { <-block })))
+	require.Eventually(t, func() bool { return loop.QueueStats().Len == 0 }, time.Second, time.Millisecond)
+	require.Equal(t, http.StatusOK, serve(handler, "/", "fill red\nupdate").Code)
+
+	rec := serve(handler, "/", "fill blue\nupdate")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Equal(t, uint64(1), loop.QueueStats().Rejected)
+
+	close(block)
+	loop.StopAndWait()
+	close(recorder.colors)
+
+	var got []color.RGBA
+	for c := range recorder.colors {
+		got = append(got, c)
+	}
+	assert.Equal(t, []color.RGBA{{R: 0xff, A: 0xff}}, got)
+	ops, err := p.Parse(strings.NewReader("update"))
+	require.NoError(t, err)
+	assert.Equal(t, &painter.FillOp{Color: color.RGBA{R: 0xff, A: 0xff}}, ops[0], "a rejected script does not change the scene")
+}
+
+func TestHttpHandlerWait(t *testing.T) {
+	recorder := &frameRecorder{colors: make(chan color.RGBA, 8)}
+	loop := &painter.Loop{Receiver: recorder, MaxFPS: 10}
+	loop.Start(context.Background(), painter.HeadlessScreen{})
+	defer loop.StopAndWait()
+	handler := HttpHandler(loop, &Parser{})
+
+	require.Equal(t, http.StatusOK, serve(handler, "/?wait=true", "fill red\nupdate").Code)
+	require.Equal(t, http.StatusOK, serve(handler, "/?wait=true", "fill blue\nupdate").Code)
+	require.Len(t, recorder.colors, 2, "the response is sent after the frame is presented")
+	assert.Equal(t, []color.RGBA{{R: 0xff, A: 0xff}, {B: 0xff, A: 0xff}}, []color.RGBA{<-recorder.colors, <-recorder.colors})
+
+	assert.Equal(t, http.StatusBadRequest, serve(handler, "/?wait=maybe", "update").Code)
+}
+
+func TestHttpHandlerBadScriptDoesNotDropQueuedWork(t *testing.T) {
+	loop := &painter.Loop{Receiver: &frameRecorder{colors: make(chan color.RGBA, 8)}, Capacity: 1, Overflow: painter.OverflowDropOldest}
+	loop.Start(context.Background(), painter.HeadlessScreen{})
+	defer loop.StopAndWait()
+	handler := HttpHandler(loop, &Parser{})
+
+	block := make(chan struct{})
+	defer close(block)
+	require.NoError(t, loop.Post(painter.OperationFunc(func(screen.Texture) { <-block })))
+	require.Eventually(t, func() bool { return loop.QueueStats().Len == 0 }, time.Second, time.Millisecond)
+	require.Equal(t, http.StatusOK, serve(handler, "/", "fill red\nupdate").Code)
+
+	require.Equal(t, http.StatusBadRequest, serve(handler, "/", "star").Code)
+	assert.Equal(t, painter.QueueStats{Len: 1, Capacity: 1}, loop.QueueStats(), "a rejected script drops nothing")
+
+	require.Equal(t, http.StatusOK, serve(handler, "/", "fill blue\nupdate").Code)
+	assert.Equal(t, uint64(1), loop.QueueStats().Dropped)
+}
+
+func TestHttpHandlerWaitSuperseded(t *testing.T) {
+	loop := &painter.Loop{Receiver: &frameRecorder{colors: make(chan color.RGBA, 8)}}
+	loop.Start(context.Background(), painter.HeadlessScreen{})
+	defer loop.StopAndWait()
+	handler := HttpHandler(loop, &Parser{})
+
+	block := make(chan struct{})
+	require.NoError(t, loop.Post(painter.OperationFunc(func(screen.Texture) { <-block })))
+	require.Eventually(t, func() bool { return loop.QueueStats().Len == 0 }, time.Second, time.Millisecond)
+
+	waited := make(chan *httptest.ResponseRecorder)
+	go func() { waited <- serve(handler, "/?wait=true", "fill red\nupdate") }()
+	require.Eventually(t, func() bool { return loop.QueueStats().Len == 1 }, time.Second, time.Millisecond)
+	require.Equal(t, http.StatusOK, serve(handler, "/", "reset\nfill blue\nupdate").Code)
+	close(block)
+
+	rec := <-waited
+	assert.Equal(t, http.StatusConflict, rec.Code, "the frame of a script replaced by reset is never presented")
+	assert.Contains(t, rec.Body.String(), painter.ErrSuperseded.Error())
+}
@@ -0,0 +1,182 @@
+package lang
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+)
+
+// JournalEntry — запис журналу команд: скрипт, успішно виконаний Parser, та час його виконання.
+type JournalEntry struct {
+	Time   time.Time `json:"time"`
+	Script string    `json:"script"`
+}
+
+// Journal дописує виконані скрипти у журнал, по одному JSON об'єкту JournalEntry на рядок.
+type Journal struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJournal створює журнал, що пише записи у w.
+func NewJournal(w io.Writer) *Journal {
+	return &Journal{w: w}
+}
+
+// OpenJournal відкриває файл журналу для дописування, створюючи його за потреби.
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewJournal(f), nil
+}
+
+// Record дописує у журнал скрипт script з часом t.
+func (j *Journal) Record(t time.Time, script string) error {
+	data, err := json.Marshal(JournalEntry{Time: t, Script: script})
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// Запис одним викликом Write, щоб рядок журналу не перемежовувався з іншими.
+	_, err = j.w.Write(append(data, '\n'))
+	return err
+}
+
+// Close закриває файл журналу, якщо журнал пише у файл.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if c, ok := j.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// ReadJournal читає всі записи журналу з r.
+func ReadJournal(r io.Reader) ([]JournalEntry, error) {
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var e JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("journal line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// ReplayOptions задає, як відтворювати журнал.
+type ReplayOptions struct {
+	// RealTime вмикає відтворення з тими ж інтервалами між скриптами, що й у журналі.
+	// Інакше скрипти виконуються одразу один за одним.
+	RealTime bool
+	// Until — час, після якого відтворення зупиняється. Нульове значення означає відтворення всього журналу.
+	Until time.Time
+}
+
+// Replay виконує скрипти журналу за допомогою p і відправляє отримані операції у loop так само,
+// як це робить HttpHandler. Скрипти, які не вдалося виконати повторно (наприклад, load без збереженої сцени),
+// пропускаються з повідомленням у лозі. Повертає кількість відтворених скриптів.
+// Анімації відтворюються за часом журналу: на час відтворення p.Clock замінюється, а наприкінці loop отримує кадр
+// на момент opts.Until (або поточний момент, якщо його не задано).
+func Replay(ctx context.Context, entries []JournalEntry, p *Parser, loop *painter.Loop, opts ReplayOptions) (int, error) {
+	var now time.Time
+	clock := p.Clock
+	p.Clock = func() time.Time { return now }
+	defer func() { p.Clock = clock }()
+
+	n := 0
+	for i, e := range entries {
+		if !opts.Until.IsZero() && e.Time.After(opts.Until) {
+			break
+		}
+		if opts.RealTime && i > 0 {
+			if err := replayFrames(ctx, p, loop, entries[i-1].Time, e.Time); err != nil {
+				return n, err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+
+		now = e.Time
+		ops, err := p.Parse(strings.NewReader(e.Script))
+		if err != nil {
+			log.Printf("Skipping journal entry at %s: %s", e.Time.Format(time.RFC3339Nano), err)
+			continue
+		}
+		if err := loop.Post(painter.OperationList(ops)); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	end := opts.Until
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if op, _ := p.Frame(end); op != nil {
+		if err := loop.Post(op); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// replayFrames очікує від from до to у реальному часі, відправляючи у loop кадри анімацій p з частотою
+// loop.FrameRate (або painter.DefaultFrameRate).
+func replayFrames(ctx context.Context, p *Parser, loop *painter.Loop, from, to time.Time) error {
+	rate := loop.FrameRate
+	if rate <= 0 {
+		rate = painter.DefaultFrameRate
+	}
+	interval := time.Second / time.Duration(rate)
+	for t := from; t.Before(to); {
+		next := to
+		if p.Animating() && t.Add(interval).Before(to) {
+			next = t.Add(interval)
+		}
+		if err := sleep(ctx, next.Sub(t)); err != nil {
+			return err
+		}
+		if op, _ := p.Frame(next); op != nil {
+			if err := loop.Post(op); err != nil {
+				return err
+			}
+		}
+		t = next
+	}
+	return nil
+}
+
+// sleep очікує протягом d або до скасування ctx.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
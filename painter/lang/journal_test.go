@@ -0,0 +1,108 @@
+package lang
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/shiny/screen"
+)
+
+// frameRecorder запам'ятовує колір лівого верхнього пікселя кожного кадру.
+type frameRecorder struct {
+	colors chan color.RGBA
+}
+
+func (r *frameRecorder) Update(t screen.Texture) {
+	r.colors <- t.(*painter.ImageTexture).RGBA().RGBAAt(0, 0)
+}
+
+func TestJournalRecordsAcceptedScripts(t *testing.T) {
+	var buf bytes.Buffer
+	p := &Parser{Journal: NewJournal(&buf)}
+
+	_, err := p.Parse(strings.NewReader("fill red\nupdate"))
+	require.NoError(t, err)
+	_, err = p.Parse(strings.NewReader("fill nope"))
+	require.Error(t, err)
+	_, err = p.Parse(strings.NewReader("figure 0.5 0.5"))
+	require.NoError(t, err)
+
+	entries, err := ReadJournal(&buf)
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "rejected scripts are not journaled")
+	assert.Equal(t, "fill red\nupdate\n", entries[0].Script)
+	assert.Equal(t, "figure 0.5 0.5\n", entries[1].Script)
+	assert.False(t, entries[1].Time.Before(entries[0].Time))
+}
+
+func TestReplayStopsAtTimestamp(t *testing.T) {
+	start := time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)
+	entries := []JournalEntry{
+		{Time: start, Script: "fill red\nupdate\n"},
+		{Time: start.Add(time.Minute), Script: "fill nope\n"},
+		{Time: start.Add(2 * time.Minute), Script: "fill lime\nupdate\n"},
+		{Time: start.Add(3 * time.Minute), Script: "fill blue\nupdate\n"},
+	}
+
+	recorder := &frameRecorder{colors: make(chan color.RGBA, len(entries))}
+	loop := &painter.Loop{Receiver: recorder}
+	loop.Start(context.Background(), painter.HeadlessScreen{})
+
+	n, err := Replay(context.Background(), entries, &Parser{}, loop, ReplayOptions{Until: start.Add(2 * time.Minute)})
+	loop.StopAndWait()
+	require.NoError(t, err)
+	assert.Equal(t, 2, n, "invalid scripts are skipped")
+
+	close(recorder.colors)
+	var got []color.RGBA
+	for c := range recorder.colors {
+		got = append(got, c)
+	}
+	assert.Equal(t, []color.RGBA{{R: 0xff, A: 0xff}, {G: 0xff, A: 0xff}}, got)
+}
+
+func TestReplayRealTime(t *testing.T) {
+	start := time.Now()
+	entries := []JournalEntry{
+		{Time: start, Script: "fill red\n"},
+		{Time: start.Add(50 * time.Millisecond), Script: "fill blue\n"},
+	}
+	loop := &painter.Loop{Receiver: &frameRecorder{}}
+	loop.Start(context.Background(), painter.HeadlessScreen{})
+	defer loop.StopAndWait()
+
+	began := time.Now()
+	_, err := Replay(context.Background(), entries, &Parser{}, loop, ReplayOptions{RealTime: true})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(began), 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Replay(ctx, entries, &Parser{}, loop, ReplayOptions{RealTime: true})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestReplayAnimations(t *testing.T) {
+	start := time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)
+	entries := []JournalEntry{
+		{Time: start, Script: "fill white\nbgrect 0 0 0.5 0.5 red id=box\nanimate box to 0.5 0 over 10s\nupdate\n"},
+	}
+
+	recorder := &frameRecorder{colors: make(chan color.RGBA, 2)}
+	loop := &painter.Loop{Receiver: recorder}
+	loop.Start(context.Background(), painter.HeadlessScreen{})
+
+	_, err := Replay(context.Background(), entries, &Parser{}, loop, ReplayOptions{Until: start.Add(time.Minute)})
+	loop.StopAndWait()
+	require.NoError(t, err)
+
+	assert.Equal(t, color.RGBA{R: 0xff, A: 0xff}, <-recorder.colors)
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, <-recorder.colors, "the last frame shows the finished animation")
+}
@@ -0,0 +1,247 @@
+package lang
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+)
+
+// Schema — JSON Schema формату команд, який приймає Parser.ParseJSON.
+//
+//go:embed schema.json
+var Schema []byte
+
+// fieldKind визначає допустимі значення поля JSON команди.
+type fieldKind int
+
+const (
+	numberField   fieldKind = iota // Число, як аргумент текстового скрипта (координати у частках розміру вікна).
+	colorField                     // Колір у будь-якому форматі ParseColor.
+	blendField                     // Назва режиму змішування.
+	nameField                      // Ідентифікатор об'єкта або назва контрольної точки чи сцени (див. validID).
+	durationField                  // Тривалість у форматі time.ParseDuration, наприклад "1.5s".
+	easingField                    // Назва функції пом'якшення анімації.
+)
+
+// jsonField описує поле JSON команди. Поля записуються у текстову команду в порядку оголошення:
+// позиційні — як аргументи, решта — як параметри key=value. Непорожнє keyword записується перед значенням.
+type jsonField struct {
+	name     string
+	kind     fieldKind
+	required bool
+	option   bool
+	keyword  string
+}
+
+var (
+	styleFields = []jsonField{
+		{name: "color", kind: colorField},
+		{name: "blend", kind: blendField, option: true},
+		{name: "opacity", kind: numberField, option: true},
+		{name: "id", kind: nameField, option: true},
+	}
+	outlineFields = append([]jsonField{{name: "width", kind: numberField}}, styleFields...)
+	nameFields    = []jsonField{{name: "name", kind: nameField, required: true}}
+)
+
+// required повертає обов'язкові числові позиційні поля з назвами names.
+func required(names ...string) []jsonField {
+	res := make([]jsonField, len(names))
+	for i, name := range names {
+		res[i] = jsonField{name: name, kind: numberField, required: true}
+	}
+	return res
+}
+
+// jsonOps описує поля кожної команди JSON формату. Має відповідати schema.json.
+var jsonOps = map[string][]jsonField{
+	"fill":       {{name: "color", kind: colorField, required: true}},
+	"update":     nil,
+	"bgrect":     append(required("x1", "y1", "x2", "y2"), styleFields...),
+	"figure":     append(required("x", "y"), styleFields...),
+	"move":       append([]jsonField{{name: "id", kind: nameField}}, required("dx", "dy")...),
+	"delete":     {{name: "id", kind: nameField, required: true}},
+	"circle":     append(required("x", "y", "r"), outlineFields...),
+	"ellipse":    append(required("x", "y", "rx", "ry"), outlineFields...),
+	"arc":        append(required("x", "y", "r", "start", "end"), outlineFields...),
+	"undo":       nil,
+	"redo":       nil,
+	"checkpoint": nameFields,
+	"restore":    nameFields,
+	"save":       nameFields,
+	"load":       nameFields,
+	"reset":      nil,
+	"use": {
+		{name: "name", kind: nameField, required: true},
+		{name: "dx", kind: numberField, required: true},
+		{name: "dy", kind: numberField, required: true},
+		{name: "id", kind: nameField, option: true},
+	},
+	"animate": {
+		{name: "id", kind: nameField, required: true},
+		{name: "x", kind: numberField, required: true, keyword: "to"},
+		{name: "y", kind: numberField, required: true},
+		{name: "duration", kind: durationField, required: true, keyword: "over"},
+		{name: "ease", kind: easingField},
+	},
+	"cancel": {{name: "id", kind: nameField}},
+}
+
+// jsonScript — тіло JSON запиту: {"ops": [{"op": "figure", "x": 0.5, "y": 0.5}, ...]}.
+type jsonScript struct {
+	Ops []map[string]json.RawMessage `json:"ops"`
+}
+
+// IsJSON перевіряє, чи має HTTP запит тіло у форматі JSON.
+func IsJSON(r *http.Request) bool {
+	mediaType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	return strings.EqualFold(strings.TrimSpace(mediaType), "application/json")
+}
+
+// ParseJSON виконує скрипт, записаний у JSON форматі (див. Schema), так само, як Parse виконує текстовий скрипт.
+func (p *Parser) ParseJSON(in io.Reader) ([]painter.Operation, error) {
+	script, err := jsonToScript(in)
+	if err != nil {
+		p.failures.add(err)
+		return nil, err
+	}
+	return p.Parse(strings.NewReader(script))
+}
+
+// jsonToScript перевіряє JSON скрипт та перетворює його на рівнозначний текстовий скрипт.
+func jsonToScript(in io.Reader) (string, error) {
+	dec := json.NewDecoder(in)
+	dec.DisallowUnknownFields()
+	var s jsonScript
+	if err := dec.Decode(&s); err != nil {
+		return "", fmt.Errorf("invalid JSON script: %w", err)
+	}
+	if s.Ops == nil {
+		return "", errors.New(`invalid JSON script: missing "ops"`)
+	}
+
+	var (
+		script strings.Builder
+		errs   ParseErrors
+	)
+	for i, op := range s.Ops {
+		line, err := jsonToCommand(op)
+		if err != nil {
+			errs = append(errs, newJSONError(i+1, op, err))
+			continue
+		}
+		script.WriteString(line)
+		script.WriteByte('\n')
+	}
+	if len(errs) > 0 {
+		return "", errs
+	}
+	return script.String(), nil
+}
+
+// newJSONError доповнює помилку err у JSON команді op з номером n контекстом команди. Token містить назву поля.
+func newJSONError(n int, op map[string]json.RawMessage, err error) *ParseError {
+	pe := &ParseError{Line: n, Err: err}
+	if json.Unmarshal(op["op"], &pe.Command) == nil {
+		pe.Expected = signatures[pe.Command]
+	}
+	var te *tokenError
+	if errors.As(err, &te) {
+		pe.Token, pe.Err = te.token, te.err
+	}
+	return pe
+}
+
+// jsonToCommand перетворює одну JSON команду на рядок текстового скрипта.
+func jsonToCommand(op map[string]json.RawMessage) (string, error) {
+	var name string
+	if raw, ok := op["op"]; !ok || json.Unmarshal(raw, &name) != nil {
+		return "", atToken("op", errors.New(`"op" must be a command name`))
+	}
+	fields, ok := jsonOps[name]
+	if !ok {
+		return "", atToken("op", fmt.Errorf("unknown command: %s", name))
+	}
+
+	known := map[string]bool{"op": true}
+	line := []string{name}
+	for _, f := range fields {
+		known[f.name] = true
+		raw, ok := op[f.name]
+		if !ok {
+			if f.required {
+				return "", atToken(f.name, fmt.Errorf("missing field %q", f.name))
+			}
+			continue
+		}
+		value, err := f.value(raw)
+		if err != nil {
+			return "", atToken(f.name, fmt.Errorf("field %q: %w", f.name, err))
+		}
+		if f.option {
+			value = f.name + "=" + value
+		}
+		if f.keyword != "" {
+			line = append(line, f.keyword)
+		}
+		line = append(line, value)
+	}
+	for key := range op {
+		if !known[key] {
+			return "", atToken(key, fmt.Errorf("unknown field %q", key))
+		}
+	}
+	return strings.Join(line, " "), nil
+}
+
+// value перевіряє значення поля та повертає його у вигляді аргументу текстової команди.
+func (f jsonField) value(raw json.RawMessage) (string, error) {
+	if f.kind == numberField {
+		var n float64
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return "", errors.New("expected a number")
+		}
+		return strconv.FormatFloat(n, 'g', -1, 64), nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", errors.New("expected a string")
+	}
+	// Перевірка значень також гарантує, що вони не розірвуть рядок команди та не додадуть до неї аргументів.
+	s = strings.TrimSpace(s)
+	switch f.kind {
+	case colorField:
+		if _, err := ParseColor(s); err != nil {
+			return "", err
+		}
+		if strings.ContainsAny(s, "\r\n") {
+			return "", fmt.Errorf("invalid color: %q", s)
+		}
+	case blendField:
+		if _, err := painter.ParseBlendMode(s); err != nil {
+			return "", err
+		}
+	case nameField:
+		if !validID(s) {
+			return "", fmt.Errorf("invalid name: %q", s)
+		}
+	case durationField:
+		if d, err := time.ParseDuration(s); err != nil || d < 0 {
+			return "", fmt.Errorf("invalid duration: %q", s)
+		}
+	case easingField:
+		if _, ok := easings[s]; !ok {
+			return "", fmt.Errorf("unknown easing: %q", s)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,128 @@
+package lang
+
+import (
+	"context"
+	"encoding/json"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONToScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		json   string
+		script string
+		err    string
+	}{
+		{
+			name:   "figure and update",
+			json:   `{"ops": [{"op": "figure", "x": 0.5, "y": 0.25}, {"op": "update"}]}`,
+			script: "figure 0.5 0.25\nupdate\n",
+		},
+		{
+			name:   "style fields",
+			json:   `{"ops": [{"op": "circle", "x": 0.5, "y": 0.5, "r": 0.1, "width": 0.01, "color": "rgb(255, 0, 0)", "blend": "xor", "opacity": 0.5, "id": "sun"}]}`,
+			script: "circle 0.5 0.5 0.1 0.01 rgb(255, 0, 0) blend=xor opacity=0.5 id=sun\n",
+		},
+		{
+			name:   "move by id",
+			json:   `{"ops": [{"op": "move", "id": "sun", "dx": 0.1, "dy": 0}]}`,
+			script: "move sun 0.1 0\n",
+		},
+		{
+			name:   "animate",
+			json:   `{"ops": [{"op": "animate", "id": "sun", "x": 0.8, "y": 0.8, "duration": "2s", "ease": "ease-in-out"}, {"op": "cancel"}]}`,
+			script: "animate sun to 0.8 0.8 over 2s ease-in-out\ncancel\n",
+		},
+		{name: "invalid duration", json: `{"ops": [{"op": "animate", "id": "sun", "x": 0, "y": 0, "duration": "2s update"}]}`, err: "invalid duration"},
+		{name: "missing ops", json: `{}`, err: `missing "ops"`},
+		{name: "unknown top-level field", json: `{"ops": [], "x": 1}`, err: "unknown field"},
+		{name: "unknown command", json: `{"ops": [{"op": "star"}]}`, err: `line 1: unknown command: star (at "op")`},
+		{name: "missing field", json: `{"ops": [{"op": "update"}, {"op": "figure", "x": 0.5}]}`, err: `line 2: missing field "y" (at "y"); expected: figure <x> <y>`},
+		{name: "unknown field", json: `{"ops": [{"op": "fill", "color": "red", "r": 1}]}`, err: `unknown field "r"`},
+		{name: "wrong type", json: `{"ops": [{"op": "figure", "x": "0.5", "y": 0.5}]}`, err: "expected a number"},
+		{name: "invalid color", json: `{"ops": [{"op": "fill", "color": "red update"}]}`, err: "unknown color"},
+		{name: "injected argument", json: `{"ops": [{"op": "delete", "id": "a b"}]}`, err: "invalid name"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			script, err := jsonToScript(strings.NewReader(tc.json))
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.script, script)
+		})
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	p := &Parser{}
+	ops, err := p.ParseJSON(strings.NewReader(`{"ops": [{"op": "fill", "color": "white"}, {"op": "figure", "x": 0.1, "y": 0.2}, {"op": "update"}]}`))
+	require.NoError(t, err)
+	require.Len(t, ops, 3)
+	assert.Equal(t, &painter.FigureOp{X: 80, Y: 160}, ops[1])
+	assert.Equal(t, painter.UpdateOp, ops[2])
+}
+
+// TestSchemaMatchesCommands перевіряє, що опубліковану схему синхронізовано з jsonOps.
+func TestSchemaMatchesCommands(t *testing.T) {
+	var schema struct {
+		Defs map[string]struct {
+			Required   []string       `json:"required"`
+			Properties map[string]any `json:"properties"`
+		} `json:"$defs"`
+	}
+	require.NoError(t, json.Unmarshal(Schema, &schema))
+
+	for op, fields := range jsonOps {
+		def, ok := schema.Defs[op+"Op"]
+		if !assert.True(t, ok, "schema has no definition for %s", op) {
+			continue
+		}
+		props, req := []string{"op"}, []string{"op"}
+		for _, f := range fields {
+			props = append(props, f.name)
+			if f.required {
+				req = append(req, f.name)
+			}
+		}
+		var schemaProps []string
+		for name := range def.Properties {
+			schemaProps = append(schemaProps, name)
+		}
+		sort.Strings(props)
+		sort.Strings(schemaProps)
+		assert.Equal(t, props, schemaProps, op)
+		assert.ElementsMatch(t, req, def.Required, op)
+	}
+}
+
+func TestHttpHandlerJSON(t *testing.T) {
+	loop := &painter.Loop{Receiver: &frameRecorder{colors: make(chan color.RGBA, 1)}}
+	loop.Start(context.Background(), painter.HeadlessScreen{})
+	defer loop.StopAndWait()
+	handler := HttpHandler(loop, &Parser{})
+
+	post := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, post(`{"ops": [{"op": "fill", "color": "red"}, {"op": "update"}]}`))
+	assert.Equal(t, http.StatusBadRequest, post(`fill red`))
+	assert.Equal(t, http.StatusBadRequest, post(`{"ops": [{"op": "figure"}]}`))
+}
@@ -0,0 +1,109 @@
+package lang
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+)
+
+// maxMacroDepth обмежує вкладеність команд use, зокрема рекурсивних.
+const maxMacroDepth = 16
+
+// macroCommands — команди, які можна використовувати у тілі макросу def. Решта команд змінюють фон,
+// історію чи збережений стан і не мають сенсу для окремої фігури.
+var macroCommands = map[string]bool{
+	"bgrect": true, "figure": true, "circle": true, "ellipse": true, "arc": true,
+	"move": true, "delete": true, "let": true, "repeat": true, "for": true, "use": true,
+}
+
+// define виконує інструкцію def <назва> { ... }: запам'ятовує тіло макросу для команди use.
+func (p *Parser) define(st statement) error {
+	fields, _ := splitFields(st.text)
+	if len(fields) != 2 {
+		return errors.New("def expects a macro name")
+	}
+	name := fields[1]
+	if !validVar(name) {
+		return atToken(name, fmt.Errorf("invalid macro name: %q", name))
+	}
+	if err := checkMacroBody(st.body); err != nil {
+		return err
+	}
+	if p.macros == nil {
+		p.macros = make(map[string][]statement)
+	}
+	p.macros[name] = st.body
+	return nil
+}
+
+// checkMacroBody перевіряє, що тіло макросу містить лише дозволені команди.
+func checkMacroBody(body []statement) error {
+	for _, st := range body {
+		if comm := st.command(); !macroCommands[comm] {
+			return fmt.Errorf("line %d: %s is not allowed in def", st.line, comm)
+		}
+		if err := checkMacroBody(st.body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// use виконує інструкцію use <назва> <dx> <dy> [id=<id>]: малює фігури макросу, зміщені на (dx, dy).
+// Фігури екземпляра отримують ідентифікатори <id>.<ідентифікатор фігури в макросі>, тож команди move та delete
+// з ідентифікатором екземпляра діють на всі його фігури. Повторне використання того ж id замінює екземпляр.
+func (p *Parser) use(st statement, errs *ParseErrors) error {
+	fields, _ := splitFields(st.text)
+	args, opts := splitOptions(fields[1:])
+	if len(args) != 3 {
+		return errors.New("use expects a macro name and an offset")
+	}
+	body, ok := p.macros[args[0]]
+	if !ok {
+		return atToken(args[0], fmt.Errorf("unknown macro: %s", args[0]))
+	}
+	offset, err := p.coords("use", args[1:], 2)
+	if err != nil {
+		return err
+	}
+	id, ok := opts["id"]
+	delete(opts, "id")
+	switch {
+	case ok && !validID(id):
+		return atToken("id="+id, fmt.Errorf("invalid object id for use: %q", id))
+	case !ok:
+		id = p.scene.NewID(args[0])
+	}
+	if err := unknownOption("use", opts); err != nil {
+		return err
+	}
+	if p.depth >= maxMacroDepth {
+		return fmt.Errorf("macros are nested deeper than %d levels", maxMacroDepth)
+	}
+
+	// Тіло макросу виконується на окремій сцені, фігури якої потім переносяться у поточну.
+	scene := p.scene
+	p.scene = painter.Scene{}
+	p.depth++
+	var bodyErrs ParseErrors
+	completed := p.exec(body, &bodyErrs)
+	p.depth--
+	instance := p.scene
+	p.scene = scene
+
+	if !completed {
+		*errs = append(*errs, bodyErrs...)
+		return errAborted
+	}
+	if len(bodyErrs) > 0 {
+		return fmt.Errorf("in macro %s: %w", args[0], bodyErrs[0])
+	}
+
+	p.scene.Delete(id)
+	for _, obj := range instance.Objects() {
+		p.scene.Put(id+"."+obj.ID, obj.Shape.Translate(offset[0], offset[1]))
+	}
+	p.changed = true
+	return nil
+}
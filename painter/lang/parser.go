@@ -4,31 +4,65 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"image/color"
 	"io"
-	"strconv"
+	"log"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/roman-mazur/architecture-lab-3/painter"
 	"github.com/roman-mazur/architecture-lab-3/ui"
 )
 
 // Parser уміє прочитати дані з вхідного io.Reader та повернути список операцій представлені вхідним скриптом.
+// Стан малюнка між викликами Parse зберігається у сцені painter.Scene, з якої і формується список операцій.
+// Кожен успішно виконаний скрипт, що змінив сцену, стає одним кроком історії для команд undo та redo.
 type Parser struct {
-
-	figures     []*painter.FigureOp
-	moveOps     []painter.Operation
-	lastBgColor painter.Operation
-	lastBgRect  *painter.BgRectOp
-	updateOp    painter.Operation
-
+	// StateDir — каталог, у якому команди save та load зберігають сцени. Якщо порожній, ці команди недоступні.
+	StateDir string
+	// Journal, якщо задано, отримує кожен успішно виконаний скрипт (див. Replay).
+	Journal *Journal
+	// Clock повертає поточний час для команди animate; якщо не задано, використовується time.Now.
+	Clock func() time.Time
+	// CanBlend повідомляє, чи підтримує екран режим змішування (зазвичай painter.Loop.CanBlend).
+	// Скрипти з непідтримуваними режимами відхиляються; якщо не задано, дозволено всі режими.
+	CanBlend func(mode painter.BlendMode) bool
+
+	mu       sync.Mutex
+	scene    painter.Scene
+	updateOp painter.Operation
+
+	history history
+	base    painter.Scene // Стан сцени після останнього запису в історію
+	changed bool          // Чи змінювалась сцена після останнього запису в історію
+
+	vars   map[string]float64     // Змінні, задані командою let; зберігаються між викликами Parse
+	macros map[string][]statement // Макроси, задані командою def; зберігаються між викликами Parse
+	steps  int                    // Кількість кроків, виконаних поточним скриптом
+	depth  int                    // Вкладеність команд use, що виконуються
+	loops  int                    // Вкладеність циклів repeat та for, що виконуються
+
+	animations []animation // Незавершені анімації, запущені командою animate; рухаються між викликами Parse
+
+	seq     uint64        // Кількість успішно виконаних скриптів
+	reset   bool          // Поточний скрипт виконав команду reset
+	skipped atomic.Uint64 // Операції скриптів з меншим номером вже не потрібно малювати (див. post)
+
+	failures errorCounter // Помилки відхилених скриптів за командою (див. ErrorCounts)
 }
 
 // initializeParserState ініціалізує початковий стан парсера
 func (p *Parser) initializeParserState() {
 
-	if p.lastBgColor == nil {
+	if p.scene.Background == nil {
 
-		p.lastBgColor = painter.OperationFunc(painter.Reset)
+		p.scene.Background = painter.OperationFunc(painter.Reset)
 
 	}
 
@@ -41,62 +75,144 @@ func (p *Parser) initializeParserState() {
 
 // Parse читає вхідний потік (наприклад, тіло HTTP запиту), розбиває його на рядки,
 // виконує парсинг кожного рядка та повертає список painter.Operation.
+// Рядок може містити кілька команд, розділених ";", та коментар, що починається з "#" (див. splitCommands).
+// Порожні рядки та рядки лише з коментарем пропускаються. Рядок не може бути довшим за 1 МБ.
+// Числові аргументи можуть бути арифметичними виразами зі змінними, заданими командою let <змінна> = <вираз>.
+// Команди у фігурних дужках після repeat <кількість> { ... } або for <змінна> in <від>..<до> { ... }
+// виконуються кілька разів; у for змінна пробігає значення від <від> до <до>, не включаючи <до>.
+// Команда def <назва> { ... } задає макрос зі складеною фігурою, а use <назва> <dx> <dy> малює його зі зміщенням.
+// Команда animate <id> to <x> <y> over <тривалість> [ease] плавно переміщує об'єкт (кадри малює painter.Loop,
+// див. Frame), а cancel [id] зупиняє анімацію.
 // Якщо виникає помилка при парсингу якоїсь команди, повертається відповідна помилка.
 func (p *Parser) Parse(in io.Reader) ([]painter.Operation, error) {
 
-	p.initializeParserState()
+	r, err := p.run(in)
+	return r.ops, err
+
+}
+
+// maxLineSize — найбільша довжина рядка скрипта в байтах.
+const maxLineSize = 1 << 20
+
+// newScanner повертає сканер рядків скрипта з in, який приймає рядки довжиною до maxLineSize.
+func newScanner(in io.Reader) *bufio.Scanner {
 	scanner := bufio.NewScanner(in)
-	scanner.Split(bufio.ScanLines)
+	scanner.Buffer(nil, maxLineSize)
+	return scanner
+}
+
+// scanError описує помилку err читання рядка line скрипта.
+func scanError(line int, err error) *ParseError {
+	if errors.Is(err, bufio.ErrTooLong) {
+		err = fmt.Errorf("line is longer than %d bytes", maxLineSize)
+	}
+	return &ParseError{Line: line, Column: 1, Err: err}
+}
+
+// result — результат виконання скрипта.
+type result struct {
+	ops    []painter.Operation
+	seq    uint64 // Порядковий номер успішно виконаного скрипта
+	urgent bool   // Скрипт скидав сцену командою reset
+}
+
+// run виконує скрипт так, як описано у Parse.
+func (p *Parser) run(in io.Reader) (result, error) {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.initializeParserState()
+	// Скрипт бачить об'єкти анімацій у положенні на момент свого виконання.
+	p.advance(p.now())
+
+	// Скрипт виконується атомарно: у разі помилки сцена та історія повертаються до початкового стану.
+	savedScene, savedHistory := p.scene.Clone(), p.history.clone()
+	savedVars, savedMacros := maps.Clone(p.vars), maps.Clone(p.macros)
+	savedAnimations := slices.Clone(p.animations)
+	p.base, p.changed, p.steps, p.reset = p.scene.Clone(), false, 0, false
+
+	scanner := newScanner(in)
+	var (
+		script strings.Builder
+		stmts  []statement
+		line   int
+	)
 	
 	for scanner.Scan() {
 
-		command := scanner.Text()
-		err := p.parse(command)
+		line++
+		text := scanner.Text()
+		script.WriteString(text)
+		script.WriteByte('\n')
 
-		if err != nil {
+		commands, offsets := splitCommands(text)
+		for i, command := range commands {
 
-			return nil, err
+			stmts = append(stmts, statement{text: command, line: line, column: utf8.RuneCountInString(text[:offsets[i]])})
 
 		}
 
 	}
-	
-	return p.finalParseResult(), nil
 
-}
+	// Скрипт, який не вдалося прочитати повністю, не виконується.
+	if err := scanner.Err(); err != nil {
 
-// finalParseResult формує кінцевий список операцій на основі поточного стану парсера
-func (p *Parser) finalParseResult() []painter.Operation {
+		errs := ParseErrors{scanError(line+1, err)}
+		p.failures.add(errs)
+		return result{}, errs
 
-	var res []painter.Operation
-	
-	if p.lastBgColor != nil {
+	}
+
+	// Після помилки виконання продовжується, щоб повідомити про всі помилкові рядки скрипта.
+	stmts, errs := buildBlocks(stmts)
+	if len(errs) == 0 {
 
-		res = append(res, p.lastBgColor)
+		p.exec(stmts, &errs)
 
 	}
-	
-	if p.lastBgRect != nil {
 
-		res = append(res, p.lastBgRect)
+	if len(errs) > 0 {
+
+		p.scene, p.history = savedScene, savedHistory
+		p.vars, p.macros = savedVars, savedMacros
+		p.animations = savedAnimations
+		p.failures.add(errs)
+		return result{}, errs
 
 	}
-	
-	if len(p.moveOps) != 0 {
 
-		res = append(res, p.moveOps...)
+	p.commit()
 
+	// Запис у журнал під блокуванням парсера, щоб порядок записів збігався з порядком виконання скриптів.
+	if p.Journal != nil {
+		if err := p.Journal.Record(time.Now(), script.String()); err != nil {
+			log.Printf("Cannot write to the journal: %s", err)
+		}
 	}
-	p.moveOps = nil
 	
-	if len(p.figures) != 0 {
+	p.seq++
+	return result{ops: p.finalParseResult(), seq: p.seq, urgent: p.reset}, nil
+
+}
 
-		for _, figure := range p.figures {
+// commit записує в історію стан сцени до змін, якщо після попереднього запису сцена змінювалась.
+func (p *Parser) commit() {
+
+	if p.changed {
+
+		p.history.record(p.base)
+		p.base, p.changed = p.scene.Clone(), false
 
-			res = append(res, figure)
-		}
 	}
-	
+
+}
+
+// finalParseResult формує кінцевий список операцій на основі поточного стану сцени
+func (p *Parser) finalParseResult() []painter.Operation {
+
+	res := p.scene.Operations()
+
 	if p.updateOp != nil {
 
 		res = append(res, p.updateOp)
@@ -110,10 +226,7 @@ func (p *Parser) finalParseResult() []painter.Operation {
 // resetParserState скидає стан парсера
 func (p *Parser) resetParserState() {
 
-	p.figures = nil
-	p.moveOps = nil
-	p.lastBgColor = nil
-	p.lastBgRect = nil
+	p.scene.Reset()
 	p.updateOp = nil
 
 }
@@ -121,7 +234,7 @@ func (p *Parser) resetParserState() {
 // parse обробляє окремий рядок команди
 func (p *Parser) parse(commandLine string) error {
 
-	fields := strings.Fields(commandLine)
+	fields, _ := splitFields(commandLine)
 
 	if len(fields) == 0 {
 
@@ -130,60 +243,460 @@ func (p *Parser) parse(commandLine string) error {
 	}
 	
 	comm := fields[0]
-	args, err := Map(fields[1:], floatStrToInt)
+	args, opts := splitOptions(fields[1:])
+
+	// Зайві аргументи команд без аргументів — найімовірніше помилка у скрипті, тож їх не ігнорують.
+	switch comm {
+	case "white", "green", "update", "undo", "redo", "reset":
+		if len(args) > 0 {
+			return atToken(args[0], fmt.Errorf("%s expects no arguments", comm))
+		}
+	}
 
-	if err != nil && len(fields) > 1 {
+	switch comm {
+	case "update", "undo", "redo", "checkpoint", "save", "animate", "cancel":
+	default:
+		p.changed = true
+	}
 
-		return errors.New("args are not integers")
-		
+	// Команди, що замінюють сцену повністю, зупиняють її анімації.
+	switch comm {
+	case "undo", "redo", "restore", "load", "reset":
+		p.cancel("")
 	}
-	
+
 	switch comm {
+	case "fill":
+		if len(args) != 1 {
+			return errors.New("fill expects exactly one color")
+		}
+		c, err := ParseColor(args[0])
+		if err != nil {
+			return atToken(args[0], err)
+		}
+		p.scene.Background = &painter.FillOp{Color: c}
 	case "white":
-		p.lastBgColor = painter.OperationFunc(painter.WhiteFill)
+		// Скорочення для "fill white", залишене для сумісності зі старими скриптами.
+		p.scene.Background = &painter.FillOp{Color: color.White}
 	case "green":
-		p.lastBgColor = painter.OperationFunc(painter.GreenFill)
+		// Скорочення для "fill lime" (чистий зелений), залишене для сумісності зі старими скриптами.
+		p.scene.Background = &painter.FillOp{Color: color.RGBA{G: 0xff, A: 0xff}}
 	case "update":
 		p.updateOp = painter.UpdateOp
 	case "bgrect":
-		if len(args) < 4 {
-			return errors.New("not enough arguments for bgrect")
+		c, err := p.coords(comm, args, 4)
+		if err != nil {
+			return err
 		}
-		p.lastBgRect = &painter.BgRectOp{
-			X1: args[0],
-			Y1: args[1],
-			X2: args[2],
-			Y2: args[3],
+		pt, err := p.style(comm, args[4:], opts, false)
+		if err != nil {
+			return err
+		}
+		// Прямокутник без id замінює попередній такий самий, як і до появи ідентифікаторів.
+		if err := p.put(comm, opts, "bgrect", &painter.BgRectOp{
+			X1:    c[0],
+			Y1:    c[1],
+			X2:    c[2],
+			Y2:    c[3],
+			Color: pt.colorFor(color.Black),
+			Blend: pt.blend,
+		}); err != nil {
+			return err
 		}
 	case "figure":
-		if len(args) < 2 {
-			return errors.New("not enough arguments for figure")
+		c, err := p.coords(comm, args, 2)
+		if err != nil {
+			return err
+		}
+		pt, err := p.style(comm, args[2:], opts, false)
+		if err != nil {
+			return err
 		}
-		figure := &painter.FigureOp{
-			X: args[0],
-			Y: args[1],
+		if err := p.put(comm, opts, "", &painter.FigureOp{
+			X:     c[0],
+			Y:     c[1],
+			Color: pt.colorFor(painter.FigureColor),
+			Blend: pt.blend,
+		}); err != nil {
+			return err
 		}
-		p.figures = append(p.figures, figure)
 	case "move":
-		if len(args) < 2 {
-			return errors.New("not enough arguments for move")
+		// move <dx> <dy> зміщує всі T-образні фігури, move <id> <dx> <dy> — лише вказаний об'єкт.
+		if len(args) == 3 {
+			c, err := p.coords(comm, args[1:], 2)
+			if err != nil {
+				return err
+			}
+			if !p.scene.Move(args[0], c[0], c[1]) {
+				return atToken(args[0], fmt.Errorf("unknown object: %s", args[0]))
+			}
+			break
 		}
-		moveOp := &painter.MoveOp{
-			X:       args[0],
-			Y:       args[1],
-			Figures: p.figures,
+		c, err := p.coords(comm, args, 2)
+		if err != nil {
+			return err
+		}
+		p.scene.MoveFigures(c[0], c[1])
+	case "delete":
+		if len(args) != 1 {
+			return errors.New("delete expects exactly one object id")
+		}
+		if !p.scene.Delete(args[0]) {
+			return atToken(args[0], fmt.Errorf("unknown object: %s", args[0]))
+		}
+	case "circle":
+		c, err := p.coords(comm, args, 3)
+		if err != nil {
+			return err
+		}
+		pt, err := p.style(comm, args[3:], opts, true)
+		if err != nil {
+			return err
+		}
+		if err := p.put(comm, opts, "", &painter.CircleOp{X: c[0], Y: c[1], R: c[2], Width: pt.width, Color: pt.colorFor(painter.FigureColor), Blend: pt.blend}); err != nil {
+			return err
+		}
+	case "ellipse":
+		c, err := p.coords(comm, args, 4)
+		if err != nil {
+			return err
+		}
+		pt, err := p.style(comm, args[4:], opts, true)
+		if err != nil {
+			return err
+		}
+		if err := p.put(comm, opts, "", &painter.EllipseOp{X: c[0], Y: c[1], RX: c[2], RY: c[3], Width: pt.width, Color: pt.colorFor(painter.FigureColor), Blend: pt.blend}); err != nil {
+			return err
+		}
+	case "arc":
+		c, err := p.coords(comm, args, 3)
+		if err != nil {
+			return err
+		}
+		if len(args) < 5 {
+			return fmt.Errorf("not enough arguments for %s", comm)
+		}
+		angles, err := Map(args[3:5], p.number)
+		if err != nil {
+			return err
+		}
+		pt, err := p.style(comm, args[5:], opts, true)
+		if err != nil {
+			return err
+		}
+		if err := p.put(comm, opts, "", &painter.ArcOp{X: c[0], Y: c[1], R: c[2], Start: angles[0], End: angles[1], Width: pt.width, Color: pt.colorFor(painter.FigureColor), Blend: pt.blend}); err != nil {
+			return err
+		}
+	case "undo":
+		p.commit()
+		prev, ok := p.history.back(p.scene)
+		if !ok {
+			return errors.New("nothing to undo")
+		}
+		p.scene = prev
+		p.base = p.scene.Clone()
+	case "redo":
+		if p.changed {
+			return errors.New("nothing to redo: scene changed after the last undo")
+		}
+		next, ok := p.history.forward(p.scene)
+		if !ok {
+			return errors.New("nothing to redo")
+		}
+		p.scene = next
+		p.base = p.scene.Clone()
+	case "checkpoint":
+		if len(args) != 1 {
+			return errors.New("checkpoint expects a name")
+		}
+		if !validID(args[0]) {
+			return atToken(args[0], errors.New("checkpoint expects a name"))
+		}
+		if p.history.checkpoints == nil {
+			p.history.checkpoints = make(map[string]painter.Scene)
+		}
+		p.history.checkpoints[args[0]] = p.scene.Clone()
+	case "restore":
+		if len(args) != 1 {
+			return errors.New("restore expects a checkpoint name")
+		}
+		cp, ok := p.history.checkpoints[args[0]]
+		if !ok {
+			return atToken(args[0], fmt.Errorf("unknown checkpoint: %s", args[0]))
+		}
+		p.scene = cp.Clone()
+	case "save":
+		if len(args) != 1 {
+			return errors.New("save expects a scene name")
+		}
+		if err := p.save(args[0]); err != nil {
+			return atToken(args[0], err)
+		}
+	case "load":
+		if len(args) != 1 {
+			return errors.New("load expects a scene name")
+		}
+		if err := p.load(args[0]); err != nil {
+			return atToken(args[0], err)
+		}
+	case "animate":
+		if err := p.animate(args); err != nil {
+			return err
+		}
+	case "cancel":
+		if len(args) > 1 {
+			return errors.New("cancel expects at most one object id")
+		}
+		if len(args) == 1 && !p.cancel(args[0]) {
+			return atToken(args[0], fmt.Errorf("no animation for object: %s", args[0]))
+		}
+		if len(args) == 0 {
+			p.cancel("")
 		}
-		p.moveOps = append(p.moveOps, moveOp)
 	case "reset":
+		p.reset = true
 		p.resetParserState()
-		p.lastBgColor = painter.OperationFunc(painter.Reset)
+		p.scene.Background = painter.OperationFunc(painter.Reset)
 	default:
-		return fmt.Errorf("unknown command: %s", comm)
+		return atToken(comm, fmt.Errorf("unknown command: %s", comm))
 	}
-	
+
+	return unknownOption(comm, opts)
+}
+
+// put додає фігуру у сцену під ідентифікатором з параметра id=. Якщо його не задано, використовується defaultID,
+// а якщо і він порожній або фігура належить макросу чи тілу циклу — новий ідентифікатор на основі назви команди.
+func (p *Parser) put(comm string, opts map[string]string, defaultID string, shape painter.Shape) error {
+	id, ok := opts["id"]
+	delete(opts, "id")
+
+	switch {
+	case ok && !validID(id):
+		return atToken("id="+id, fmt.Errorf("invalid object id for %s: %q", comm, id))
+	case !ok && defaultID != "" && p.depth == 0 && p.loops == 0:
+		id = defaultID
+	case !ok:
+		id = p.scene.NewID(comm)
+	}
+
+	p.scene.Put(id, shape)
 	return nil
 }
 
+// validID перевіряє, що ідентифікатор починається з літери або "_" і містить лише літери, цифри, "_", "-" та ".",
+// тож його неможливо сплутати з числовим аргументом команди.
+func validID(id string) bool {
+	for i, r := range id {
+		if unicode.IsLetter(r) || r == '_' {
+			continue
+		}
+		if i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.') {
+			continue
+		}
+		return false
+	}
+	return id != ""
+}
+
+// coords обчислює перші n аргументів команди comm та перетворює їх у координати вікна.
+func (p *Parser) coords(comm string, args []string, n int) ([]int, error) {
+	if len(args) < n {
+		return nil, fmt.Errorf("not enough arguments for %s", comm)
+	}
+	res, err := Map(args[:n], func(arg string) (int, error) {
+		v, err := p.number(arg)
+		if err != nil {
+			return 0, err
+		}
+		return toWindow(v), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// paint описує оформлення фігури, задане необов'язковими аргументами команди.
+type paint struct {
+	width   int
+	color   color.Color
+	blend   painter.BlendMode
+	opacity float64
+}
+
+// colorFor повертає колір фігури з урахуванням прозорості. Якщо колір не задано, використовується def,
+// а якщо прозорість теж не задано — nil, щоб операція застосувала власний колір за замовчуванням.
+func (pt paint) colorFor(def color.Color) color.Color {
+	if pt.opacity == 1 {
+		return pt.color
+	}
+	if pt.color == nil {
+		return painter.WithOpacity(def, pt.opacity)
+	}
+	return painter.WithOpacity(pt.color, pt.opacity)
+}
+
+// style розбирає необов'язкові аргументи оформлення фігури, що йдуть після координат:
+//   - колір (будь-який формат ParseColor);
+//   - товщину контуру, якщо withWidth дорівнює true (числовий аргумент або вираз);
+//   - параметри blend=<режим> (over, src, multiply, screen, xor) та opacity=<від 0 до 1>.
+//
+// Відсутня товщина означає заповнену фігуру, відсутній колір — колір фігури за замовчуванням.
+// Розібрані параметри видаляються з opts.
+func (p *Parser) style(comm string, args []string, opts map[string]string, withWidth bool) (paint, error) {
+	pt := paint{opacity: 1}
+	hasWidth := false
+
+	if value, ok := opts["blend"]; ok {
+		mode, err := painter.ParseBlendMode(value)
+		if err != nil {
+			return pt, atToken("blend="+value, fmt.Errorf("invalid argument for %s: %w", comm, err))
+		}
+		if p.CanBlend != nil && !p.CanBlend(mode) {
+			return pt, atToken("blend="+value, fmt.Errorf("blend mode %s is not supported by the screen", mode))
+		}
+		pt.blend = mode
+		delete(opts, "blend")
+	}
+	if value, ok := opts["opacity"]; ok {
+		o, err := eval(value, p.vars)
+		if err != nil || o < 0 || o > 1 {
+			return pt, atToken("opacity="+value, fmt.Errorf("invalid opacity for %s: %s", comm, value))
+		}
+		pt.opacity = o
+		delete(opts, "opacity")
+	}
+
+	for _, arg := range args {
+		// Аргумент, що не є кольором, але обчислюється як вираз, задає товщину контуру.
+		if _, err := ParseColor(arg); err != nil && withWidth && !hasWidth {
+			v, err := eval(arg, p.vars)
+			if err != nil {
+				return pt, atToken(arg, fmt.Errorf("invalid argument for %s: %w", comm, err))
+			}
+			w := toWindow(v)
+			if w < 0 {
+				return pt, atToken(arg, fmt.Errorf("invalid outline width for %s: %s", comm, arg))
+			}
+			pt.width, hasWidth = w, true
+			continue
+		}
+		if pt.color != nil {
+			return pt, atToken(arg, fmt.Errorf("too many arguments for %s", comm))
+		}
+		c, err := ParseColor(arg)
+		if err != nil {
+			return pt, atToken(arg, fmt.Errorf("invalid argument for %s: %w", comm, err))
+		}
+		pt.color = c
+	}
+	return pt, nil
+}
+
+// splitCommands розбиває рядок скрипта на команди, розділені ";", та відкидає коментар.
+// Фігурні дужки блоків повертаються як окремі команди "{" та "}".
+// Коментар починається з "#" на місці назви команди або з "#", після якого йде пробіл чи кінець рядка;
+// аргумент на кшталт "#fff" залишається кольором. Символи у дужках не розділяють команди і не починають коментар.
+// Порожні команди пропускаються. Разом з командами повертає їхні зміщення у рядку (у байтах).
+func splitCommands(line string) (commands []string, offsets []int) {
+	var (
+		depth      int
+		start      int
+		atCommand  = true // Від початку команди були лише пробіли.
+		fieldStart = true // Попередній символ відокремлює аргументи.
+	)
+	add := func(end int) {
+		if strings.TrimSpace(line[start:end]) != "" {
+			commands, offsets = append(commands, line[start:end]), append(offsets, start)
+		}
+	}
+
+	for i, r := range line {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case depth > 0:
+		case r == ';':
+			add(i)
+			start, atCommand, fieldStart = i+1, true, true
+			continue
+		case r == '{' || r == '}':
+			add(i)
+			start = i
+			add(i + 1)
+			start, atCommand, fieldStart = i+1, true, true
+			continue
+		case r == '#' && fieldStart:
+			next, _ := utf8.DecodeRuneInString(line[i+1:])
+			if atCommand || i+1 == len(line) || unicode.IsSpace(next) {
+				add(i)
+				return commands, offsets
+			}
+		}
+		space := unicode.IsSpace(r)
+		atCommand, fieldStart = atCommand && space, space
+	}
+	add(len(line))
+	return commands, offsets
+}
+
+// splitOptions відокремлює іменовані параметри виду key=value від позиційних аргументів команди.
+func splitOptions(fields []string) ([]string, map[string]string) {
+	var args []string
+	opts := make(map[string]string)
+	for _, f := range fields {
+		if key, value, ok := strings.Cut(f, "="); ok {
+			opts[key] = value
+			continue
+		}
+		args = append(args, f)
+	}
+	return args, opts
+}
+
+// unknownOption повертає помилку для параметрів opts, які команда comm не розібрала.
+// Якщо таких параметрів кілька, повідомляється про перший за алфавітом, щоб помилка не залежала від порядку обходу map.
+func unknownOption(comm string, opts map[string]string) error {
+	if len(opts) == 0 {
+		return nil
+	}
+	key := slices.Min(slices.Collect(maps.Keys(opts)))
+	return atToken(key+"="+opts[key], fmt.Errorf("unknown option for %s: %s", comm, key))
+}
+
+// splitFields розбиває рядок команди на аргументи за пробілами, не розриваючи вирази у дужках,
+// тому колір на кшталт "rgb(255, 0, 0)" залишається одним аргументом. Разом з аргументами повертає
+// їхні зміщення у рядку (у байтах).
+func splitFields(line string) (fields []string, offsets []int) {
+	var (
+		depth int
+		start = -1
+	)
+	for i, r := range line {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case unicode.IsSpace(r) && depth == 0:
+			if start >= 0 {
+				fields, offsets = append(fields, line[start:i]), append(offsets, start)
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		fields, offsets = append(fields, line[start:]), append(offsets, start)
+	}
+	return fields, offsets
+}
+
 // Map — узагальнена функція, яка приймає слайс in типу T, застосовує до кожного елемента функцію f,
 // що повертає значення типу U або помилку, і повертає слайс значень типу U або помилку.
 func Map[T any, U any](in []T, f func(T) (U, error)) ([]U, error) {
@@ -198,14 +711,8 @@ func Map[T any, U any](in []T, f func(T) (U, error)) ([]U, error) {
 	return out, nil
 }
 
-// floatStrToInt перетворює рядок, що містить число з плаваючою точкою,
-// у ціле число, масштабоване за допомогою ui.WINDOW_SIZE.
-// Наприклад, якщо рядок містить "0.1" і ui.WINDOW_SIZE дорівнює 800,
-// повернеться int(0.1 * 800) = 80.
-func floatStrToInt(arg string) (int, error) {
-	fl, err := strconv.ParseFloat(arg, 64)
-	if err != nil {
-		return 0, err
-	}
-	return int(fl * ui.WINDOW_SIZE), nil
+// toWindow перетворює число з плаваючою точкою у ціле число, масштабоване за допомогою ui.WINDOW_SIZE.
+// Наприклад, якщо ui.WINDOW_SIZE дорівнює 800, для 0.1 повернеться int(0.1 * 800) = 80.
+func toWindow(v float64) int {
+	return int(v * ui.WINDOW_SIZE)
 }
\ No newline at end of file
@@ -2,6 +2,8 @@ package lang
 
 import (
 	"fmt"
+	"image/color"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -43,8 +45,33 @@ func TestParse(t *testing.T) {
 		},
 		{
 			name:        "move",
-			command:     "move 0.1 0.1",
-			expectOp:    &painter.MoveOp{X: 80, Y: 80},
+			command:     "figure 0.5 0.5\nmove 0.1 0.1",
+			expectOp:    &painter.FigureOp{X: 480, Y: 480},
+		},
+		{
+			name:     "move-by-id",
+			command:  "figure id=a 0.5 0.5\nfigure id=b 0.1 0.1\nmove a 0.1 0",
+			expectOp: &painter.FigureOp{X: 480, Y: 400},
+		},
+		{
+			name:        "move-unknown-id",
+			command:     "move a 0.1 0",
+			expectError: true,
+		},
+		{
+			name:        "delete-unknown-id",
+			command:     "delete a",
+			expectError: true,
+		},
+		{
+			name:        "invalid-id",
+			command:     "figure id=1a 0.5 0.5",
+			expectError: true,
+		},
+		{
+			name:        "unknown-option",
+			command:     "figure size=2 0.5 0.5",
+			expectError: true,
 		},
 		{
 			name:        "move-error",
@@ -56,6 +83,101 @@ func TestParse(t *testing.T) {
 			command:     "update",
 			expectOp:    painter.UpdateOp,
 		},
+		{
+			name:     "circle",
+			command:  "circle 0.5 0.5 0.1",
+			expectOp: &painter.CircleOp{X: 400, Y: 400, R: 80},
+		},
+		{
+			name:     "circle-outline",
+			command:  "circle 0.5 0.5 0.1 0.01",
+			expectOp: &painter.CircleOp{X: 400, Y: 400, R: 80, Width: 8},
+		},
+		{
+			name:        "circle-error",
+			command:     "circle 0.5 0.5",
+			expectError: true,
+		},
+		{
+			name:     "ellipse",
+			command:  "ellipse 0.5 0.5 0.2 0.1",
+			expectOp: &painter.EllipseOp{X: 400, Y: 400, RX: 160, RY: 80},
+		},
+		{
+			name:        "ellipse-error",
+			command:     "ellipse 0.5 0.5 0.2 0.1 0.01 0.02",
+			expectError: true,
+		},
+		{
+			name:     "arc",
+			command:  "arc 0.5 0.5 0.25 0 90 0.05",
+			expectOp: &painter.ArcOp{X: 400, Y: 400, R: 200, Start: 0, End: 90, Width: 40},
+		},
+		{
+			name:        "arc-error",
+			command:     "arc 0.5 0.5 0.25 zero 90",
+			expectError: true,
+		},
+		{
+			name:     "fill",
+			command:  "fill #ff000080",
+			expectOp: &painter.FillOp{Color: color.NRGBA{R: 255, A: 128}},
+		},
+		{
+			name:        "fill-error",
+			command:     "fill nocolor",
+			expectError: true,
+		},
+		{
+			name:     "white",
+			command:  "white",
+			expectOp: &painter.FillOp{Color: color.White},
+		},
+		{
+			name:     "rectangle-color",
+			command:  "bgrect 0.25 0.25 0.75 0.75 rgb(10, 20, 30)",
+			expectOp: &painter.BgRectOp{X1: 200, Y1: 200, X2: 600, Y2: 600, Color: color.NRGBA{R: 10, G: 20, B: 30, A: 255}},
+		},
+		{
+			name:     "figure-color",
+			command:  "figure 0.5 0.5 red",
+			expectOp: &painter.FigureOp{X: 400, Y: 400, Color: color.RGBA{R: 255, A: 255}},
+		},
+		{
+			name:        "figure-color-error",
+			command:     "figure 0.5 0.5 red blue",
+			expectError: true,
+		},
+		{
+			name:     "circle-outline-color",
+			command:  "circle 0.5 0.5 0.1 #00f 0.01",
+			expectOp: &painter.CircleOp{X: 400, Y: 400, R: 80, Width: 8, Color: color.NRGBA{B: 255, A: 255}},
+		},
+		{
+			name:     "figure-blend",
+			command:  "figure 0.5 0.5 blend=multiply",
+			expectOp: &painter.FigureOp{X: 400, Y: 400, Blend: painter.BlendMultiply},
+		},
+		{
+			name:     "rectangle-opacity",
+			command:  "bgrect 0.25 0.25 0.75 0.75 opacity=0.5",
+			expectOp: &painter.BgRectOp{X1: 200, Y1: 200, X2: 600, Y2: 600, Color: color.NRGBA{A: 128}},
+		},
+		{
+			name:     "circle-color-opacity",
+			command:  "circle 0.5 0.5 0.1 red opacity=0.5 blend=screen",
+			expectOp: &painter.CircleOp{X: 400, Y: 400, R: 80, Color: color.NRGBA{R: 255, A: 128}, Blend: painter.BlendScreen},
+		},
+		{
+			name:        "blend-error",
+			command:     "figure 0.5 0.5 blend=overlay",
+			expectError: true,
+		},
+		{
+			name:        "opacity-error",
+			command:     "figure 0.5 0.5 opacity=2",
+			expectError: true,
+		},
 		{
 			name:        "invalid command",
 			command:     "invalid52",
@@ -98,14 +220,14 @@ func assertOperations(t *testing.T, expected, actual painter.Operation) bool {
 		if !ok {
 			return false
 		}
-		return exp.X == act.X && exp.Y == act.Y
+		return exp.X == act.X && exp.Y == act.Y && exp.Color == act.Color && exp.Blend == act.Blend
 		
 	case *painter.BgRectOp:
 		act, ok := actual.(*painter.BgRectOp)
 		if !ok {
 			return false
 		}
-		return exp.X1 == act.X1 && exp.Y1 == act.Y1 && exp.X2 == act.X2 && exp.Y2 == act.Y2
+		return exp.X1 == act.X1 && exp.Y1 == act.Y1 && exp.X2 == act.X2 && exp.Y2 == act.Y2 && exp.Color == act.Color && exp.Blend == act.Blend
 
 	
 	case *painter.MoveOp:
@@ -122,3 +244,303 @@ func assertOperations(t *testing.T, expected, actual painter.Operation) bool {
 		return false
 	}
 }
+
+// TestParseScene перевіряє, що список операцій формується зі сцени з урахуванням ідентифікаторів об'єктів.
+func TestParseScene(t *testing.T) {
+	parser := &Parser{}
+
+	_, err := parser.Parse(strings.NewReader("bgrect 0.1 0.1 0.2 0.2\nfigure id=a 0.2 0.2\nfigure id=b 0.5 0.5\ncircle id=c 0.5 0.5 0.1"))
+	require.NoError(t, err)
+
+	ops, err := parser.Parse(strings.NewReader("move a 0.1 0\ndelete c\nbgrect 0.3 0.3 0.4 0.4\nupdate"))
+	require.NoError(t, err)
+
+	require.Len(t, ops, 5)
+	assert.True(t, assertOperations(t, &painter.BgRectOp{X1: 240, Y1: 240, X2: 320, Y2: 320}, ops[1]), "bgrect without id replaces the previous one in place")
+	assert.True(t, assertOperations(t, &painter.FigureOp{X: 240, Y: 160}, ops[2]))
+	assert.True(t, assertOperations(t, &painter.FigureOp{X: 400, Y: 400}, ops[3]))
+	assert.Equal(t, painter.UpdateOp, ops[4])
+
+	ops, err = parser.Parse(strings.NewReader("move 0 0.1"))
+	require.NoError(t, err)
+	assert.True(t, assertOperations(t, &painter.BgRectOp{X1: 240, Y1: 240, X2: 320, Y2: 320}, ops[1]), "move without id affects figures only")
+	assert.True(t, assertOperations(t, &painter.FigureOp{X: 240, Y: 240}, ops[2]))
+	assert.True(t, assertOperations(t, &painter.FigureOp{X: 400, Y: 480}, ops[3]))
+}
+
+// figuresOf повертає координати T-образних фігур зі списку операцій.
+func figuresOf(ops []painter.Operation) [][2]int {
+	var res [][2]int
+	for _, op := range ops {
+		if f, ok := op.(*painter.FigureOp); ok {
+			res = append(res, [2]int{f.X, f.Y})
+		}
+	}
+	return res
+}
+
+// TestParseHistory перевіряє команди undo, redo, checkpoint та restore.
+func TestParseHistory(t *testing.T) {
+	parser := &Parser{}
+	parse := func(script string) []painter.Operation {
+		t.Helper()
+		ops, err := parser.Parse(strings.NewReader(script))
+		require.NoError(t, err)
+		return ops
+	}
+
+	parse("figure id=a 0.1 0.1\nfigure id=b 0.2 0.2")
+	parse("move a 0.1 0\ncheckpoint moved")
+	parse("delete b")
+
+	assert.Equal(t, [][2]int{{160, 80}, {160, 160}}, figuresOf(parse("undo")), "undo reverts the whole previous script")
+	assert.Equal(t, [][2]int{{80, 80}, {160, 160}}, figuresOf(parse("undo")))
+	assert.Equal(t, [][2]int{{160, 80}, {160, 160}}, figuresOf(parse("redo")))
+	assert.Equal(t, [][2]int{{160, 80}}, figuresOf(parse("redo")))
+
+	_, err := parser.Parse(strings.NewReader("redo"))
+	assert.Error(t, err, "nothing left to redo")
+
+	assert.Empty(t, figuresOf(parse("reset")))
+	assert.Equal(t, [][2]int{{160, 80}, {160, 160}}, figuresOf(parse("restore moved")))
+	assert.Empty(t, figuresOf(parse("undo")), "restore is undoable")
+
+	_, err = parser.Parse(strings.NewReader("restore missing"))
+	assert.Error(t, err)
+}
+
+// TestParseIsAtomic перевіряє, що скрипт з помилкою не змінює стан парсера.
+func TestParseIsAtomic(t *testing.T) {
+	parser := &Parser{}
+	_, err := parser.Parse(strings.NewReader("figure id=a 0.1 0.1"))
+	require.NoError(t, err)
+
+	_, err = parser.Parse(strings.NewReader("move a 0.1 0.1\ndelete a\nfigure 0.5"))
+	require.Error(t, err)
+
+	ops, err := parser.Parse(strings.NewReader("update"))
+	require.NoError(t, err)
+	assert.Equal(t, [][2]int{{80, 80}}, figuresOf(ops))
+
+	ops, err = parser.Parse(strings.NewReader("undo"))
+	require.NoError(t, err)
+	assert.Empty(t, figuresOf(ops), "the failed script is not recorded in history")
+}
+
+func TestParseRejectsUnsupportedBlend(t *testing.T) {
+	parser := &Parser{CanBlend: func(mode painter.BlendMode) bool { return mode == painter.BlendOver }}
+
+	_, err := parser.Parse(strings.NewReader("figure 0.5 0.5 blend=over\nfigure 0.5 0.5 blend=multiply"))
+	var errs ParseErrors
+	require.ErrorAs(t, err, &errs)
+	require.Len(t, errs, 1)
+	assert.Equal(t, 2, errs[0].Line)
+	assert.Equal(t, "blend=multiply", errs[0].Token)
+	assert.EqualError(t, errs[0].Err, "blend mode multiply is not supported by the screen")
+}
+
+func TestParseSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	parser := &Parser{StateDir: dir}
+
+	_, err := parser.Parse(strings.NewReader("fill red\nfigure id=a 0.1 0.1 blend=multiply\nsave logo"))
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "logo.json"))
+
+	other := &Parser{StateDir: dir}
+	ops, err := other.Parse(strings.NewReader("figure 0.5 0.5\nload logo\nupdate"))
+	require.NoError(t, err)
+	require.Len(t, ops, 3)
+	assert.Equal(t, &painter.FillOp{Color: color.NRGBA{R: 0xff, A: 0xff}}, ops[0])
+	assert.Equal(t, &painter.FigureOp{X: 80, Y: 80, Blend: painter.BlendMultiply}, ops[1])
+	assert.Equal(t, painter.UpdateOp, ops[2])
+
+	_, err = other.Parse(strings.NewReader("load missing"))
+	assert.Error(t, err)
+	_, err = other.Parse(strings.NewReader("save ../escape"))
+	assert.Error(t, err)
+	_, err = (&Parser{}).Parse(strings.NewReader("save logo"))
+	assert.Error(t, err, "persistence is disabled without a state directory")
+}
+
+func TestSplitCommands(t *testing.T) {
+	tests := []struct {
+		line     string
+		commands []string
+		offsets  []int
+	}{
+		{line: "", commands: nil},
+		{line: "   ", commands: nil},
+		{line: "# comment", commands: nil},
+		{line: "  #comment", commands: nil},
+		{line: "fill #fff", commands: []string{"fill #fff"}, offsets: []int{0}},
+		{line: "fill #fff # white", commands: []string{"fill #fff "}, offsets: []int{0}},
+		{line: "fill red#", commands: []string{"fill red#"}, offsets: []int{0}},
+		{line: "fill red; update", commands: []string{"fill red", " update"}, offsets: []int{0, 9}},
+		{line: "fill red;; ;update;", commands: []string{"fill red", "update"}, offsets: []int{0, 12}},
+		{line: "figure 0.1 0.1;#figure 0.2 0.2", commands: []string{"figure 0.1 0.1"}, offsets: []int{0}},
+		{line: "fill rgb(1;2#3)", commands: []string{"fill rgb(1;2#3)"}, offsets: []int{0}},
+	}
+	for _, tc := range tests {
+		commands, offsets := splitCommands(tc.line)
+		assert.Equal(t, tc.commands, commands, tc.line)
+		if tc.offsets != nil {
+			assert.Equal(t, tc.offsets, offsets, tc.line)
+		}
+	}
+}
+
+func TestParseCommentsAndBlankLines(t *testing.T) {
+	ops, err := (&Parser{}).Parse(strings.NewReader("# header\n\nfill #fff # background\n\n  \nfigure 0.1 0.1; figure 0.2 0.2\nupdate # done\n"))
+	require.NoError(t, err)
+	assert.Equal(t, [][2]int{{80, 80}, {160, 160}}, figuresOf(ops))
+	assert.Equal(t, painter.UpdateOp, ops[len(ops)-1])
+
+	_, err = (&Parser{}).Parse(strings.NewReader("fill red; figure 0.1 x"))
+	var errs ParseErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, 1, errs[0].Line)
+	assert.Equal(t, 22, errs[0].Column, "column is counted from the start of the line")
+}
+
+func TestParseVariablesAndLoops(t *testing.T) {
+	p := &Parser{}
+	ops, err := p.Parse(strings.NewReader(`
+let step = 0.1
+for i in 0..3 {
+	figure 0.1+i*step 0.5
+}
+repeat 2 { move 0 step }
+`))
+	require.NoError(t, err)
+	assert.Equal(t, [][2]int{{80, 560}, {160, 560}, {240, 560}}, figuresOf(ops))
+
+	ops, err = p.Parse(strings.NewReader("figure step (step * 2)\nfor r in 1..3 { for c in 0..r { circle c r 0.01 } }"))
+	require.NoError(t, err, "variables are kept between scripts")
+	assert.Contains(t, figuresOf(ops), [2]int{80, 160})
+	assert.Len(t, ops, 1+3+1+3)
+
+	_, err = p.Parse(strings.NewReader("figure i 0.5"))
+	assert.ErrorContains(t, err, "unknown variable: i", "the loop variable is scoped to the loop")
+}
+
+func TestParseLoopBgRects(t *testing.T) {
+	p := &Parser{}
+	ops, err := p.Parse(strings.NewReader("for i in 0..3 { bgrect i*0.2 0 i*0.2+0.1 0.1 }\nrepeat 2 { bgrect 0 0.5 0.1 0.6 }"))
+	require.NoError(t, err)
+
+	var rects []*painter.BgRectOp
+	for _, op := range ops {
+		if r, ok := op.(*painter.BgRectOp); ok {
+			rects = append(rects, r)
+		}
+	}
+	require.Len(t, rects, 5, "each iteration draws its own rectangle")
+	assert.Equal(t, 160, rects[1].X1)
+
+	ops, err = p.Parse(strings.NewReader("bgrect 0.9 0.9 1 1\nbgrect 0.8 0.8 0.9 0.9"))
+	require.NoError(t, err)
+	assert.Len(t, ops, 1+5+1, "bgrect outside of loops still replaces the previous one")
+}
+
+func TestParseBlockErrors(t *testing.T) {
+	tests := []struct {
+		script string
+		err    string
+	}{
+		{script: "repeat 2\nfigure 0.1 0.1", err: "line 1, column 1: repeat expects a block"},
+		{script: "repeat 2 {\nfigure 0.1 0.1", err: "line 1, column 1: missing }"},
+		{script: "figure 0.1 0.1 }", err: "line 1, column 16: unexpected }"},
+		{script: "repeat -1 { }", err: "invalid number of iterations"},
+		{script: "for i in 0 { }", err: "range must look like"},
+		{script: "let 1x = 2", err: "invalid variable name"},
+		{script: "let x = y + 1", err: "unknown variable: y"},
+		{script: "repeat 1000000 { update }", err: "exceeds the limit"},
+		{script: "repeat 1e9 { }", err: "exceeds the limit"},
+	}
+	for _, tc := range tests {
+		_, err := (&Parser{}).Parse(strings.NewReader(tc.script))
+		assert.ErrorContains(t, err, tc.err, tc.script)
+	}
+}
+
+func TestParseLoopStopsAfterFirstFailedIteration(t *testing.T) {
+	_, err := (&Parser{}).Parse(strings.NewReader("for i in 0..100 { figure i nope }"))
+
+	var errs ParseErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, 28, errs[0].Column)
+}
+
+func TestParseRestoresVariablesOnError(t *testing.T) {
+	p := &Parser{}
+	_, err := p.Parse(strings.NewReader("let x = 0.1"))
+	require.NoError(t, err)
+	_, err = p.Parse(strings.NewReader("let x = 0.5\nstar"))
+	require.Error(t, err)
+
+	ops, err := p.Parse(strings.NewReader("figure x x"))
+	require.NoError(t, err)
+	assert.Equal(t, [][2]int{{80, 80}}, figuresOf(ops))
+}
+
+func TestParseMacros(t *testing.T) {
+	p := &Parser{}
+	_, err := p.Parse(strings.NewReader(`
+def logo {
+	bgrect 0 0 0.1 0.1 id=box
+	figure 0.05 0.05
+}
+`))
+	require.NoError(t, err)
+
+	ops, err := p.Parse(strings.NewReader("use logo 0.3 0.3\nuse logo 0.5 0 id=right\nupdate"))
+	require.NoError(t, err, "macros are kept between scripts")
+	require.Len(t, ops, 1+4+1)
+	assert.Equal(t, &painter.BgRectOp{X1: 240, Y1: 240, X2: 320, Y2: 320}, ops[1])
+	assert.Equal(t, [][2]int{{280, 280}, {440, 40}}, figuresOf(ops))
+
+	ops, err = p.Parse(strings.NewReader("move right 0 0.1\ndelete logo1"))
+	require.NoError(t, err)
+	assert.Equal(t, [][2]int{{440, 120}}, figuresOf(ops), "move and delete act on the whole instance")
+
+	ops, err = p.Parse(strings.NewReader("use logo 0 0 id=right"))
+	require.NoError(t, err)
+	assert.Equal(t, [][2]int{{40, 40}}, figuresOf(ops), "reusing an instance id replaces the instance")
+}
+
+func TestParseNestedMacros(t *testing.T) {
+	p := &Parser{}
+	ops, err := p.Parse(strings.NewReader("def dot { circle 0 0 0.01 }\ndef row { for i in 0..3 { use dot i*0.1 0 } }\nuse row 0.1 0.1 id=r"))
+	require.NoError(t, err)
+	require.Len(t, ops, 1+3)
+	assert.Equal(t, &painter.CircleOp{X: 160, Y: 80, R: 8}, ops[2])
+
+	_, err = p.Parse(strings.NewReader("def loop { use loop 0 0 }\nuse loop 0 0"))
+	assert.ErrorContains(t, err, "nested deeper")
+}
+
+func TestParseMacroErrors(t *testing.T) {
+	tests := []struct {
+		script string
+		err    string
+	}{
+		{script: "def bad { fill red }", err: "fill is not allowed in def"},
+		{script: "def bad { repeat 2 { undo } }", err: "undo is not allowed in def"},
+		{script: "def 1bad { }", err: "invalid macro name"},
+		{script: "use missing 0 0", err: "unknown macro: missing"},
+		{script: "def m { figure x 0 }\nuse m 0 0", err: "in macro m: line 1, column 16: unknown variable: x"},
+		{script: "def m { }\nuse m 0", err: "use expects a macro name and an offset"},
+	}
+	for _, tc := range tests {
+		_, err := (&Parser{}).Parse(strings.NewReader(tc.script))
+		assert.ErrorContains(t, err, tc.err, tc.script)
+	}
+
+	p := &Parser{}
+	_, err := p.Parse(strings.NewReader("def m { figure 0 0 }\nstar"))
+	require.Error(t, err)
+	_, err = p.Parse(strings.NewReader("use m 0 0"))
+	assert.ErrorContains(t, err, "unknown macro", "definitions of a failed script are discarded")
+}
@@ -0,0 +1,95 @@
+package lang
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+)
+
+// errNoStateDir повертається командами save та load, якщо каталог для збереження сцен не налаштовано.
+var errNoStateDir = errors.New("scene persistence is disabled: state directory is not set")
+
+// Save зберігає поточну сцену у файл <StateDir>/<name>.json.
+func (p *Parser) Save(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.initializeParserState()
+	return p.save(name)
+}
+
+func (p *Parser) save(name string) error {
+	path, err := p.statePath(name)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(&p.scene, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(p.StateDir, 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// load замінює поточну сцену збереженою у файлі <StateDir>/<name>.json.
+func (p *Parser) load(name string) error {
+	path, err := p.statePath(name)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var scene painter.Scene
+	if err := json.Unmarshal(data, &scene); err != nil {
+		return fmt.Errorf("cannot load scene %s: %w", name, err)
+	}
+	if scene.Background == nil {
+		scene.Background = painter.OperationFunc(painter.Reset)
+	}
+	p.scene = scene
+	return nil
+}
+
+func (p *Parser) statePath(name string) (string, error) {
+	if p.StateDir == "" {
+		return "", errNoStateDir
+	}
+	if !validID(name) {
+		return "", fmt.Errorf("invalid scene name: %q", name)
+	}
+	return filepath.Join(p.StateDir, name+".json"), nil
+}
+
+// writeFileAtomic записує дані у тимчасовий файл у тому ж каталозі та перейменовує його на path,
+// тож збій під час запису не може залишити пошкоджений файл.
+func writeFileAtomic(path string, data []byte) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,169 @@
+package lang
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/roman-mazur/architecture-lab-3/painter"
+)
+
+// WsMessage — повідомлення, яке сервер надсилає клієнту WebSocket з'єднання у форматі JSON.
+type WsMessage struct {
+	// Type дорівнює "ack", коли кадр передано у painter.Receiver, або "error", якщо рядок скрипта не вдалося обробити.
+	Type string `json:"type"`
+	// Line — номер рядка скрипта в межах з'єднання (починаючи з 1), якого стосується повідомлення.
+	Line int `json:"line"`
+	// Frame — порядковий номер кадру в межах з'єднання для повідомлень "ack".
+	Frame int `json:"frame,omitempty"`
+	// Error містить текст помилки для повідомлень "error".
+	Error string `json:"error,omitempty"`
+}
+
+// pendingMessage — повідомлення, яке можна надіслати лише після закриття каналу ready.
+type pendingMessage struct {
+	msg   WsMessage
+	ready <-chan struct{}
+}
+
+// closedChan — канал для повідомлень, які можна надсилати одразу.
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+var upgrader = websocket.Upgrader{}
+
+// WebSocketHandler конструює обробник WebSocket з'єднань. Клієнт надсилає текстові повідомлення з рядками скрипта,
+// кожен рядок одразу обробляється Parser; рядки багаторядкового блоку { } (repeat, for, def) можуть надходити
+// в різних повідомленнях і виконуються разом, щойно блок закрито. Після кожної команди update поточний стан
+// відправляється у painter.Loop, а клієнт отримує повідомлення "ack", щойно кадр передано у painter.Receiver.
+// На кожен рядок (чи блок) з помилкою клієнт отримує повідомлення "error" з номером його першого рядка.
+// Повідомлення надходять у тому ж порядку, що й рядки, яких вони стосуються.
+func WebSocketHandler(loop *painter.Loop, p *Parser) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(rw, r, nil)
+		if err != nil {
+			log.Printf("WebSocket upgrade failed: %s", err)
+			return
+		}
+		defer conn.Close()
+
+		pending := make(chan pendingMessage, 64)
+		closed := make(chan struct{})
+		go writeMessages(conn, pending, closed)
+		defer close(pending)
+
+		var (
+			line, frame int
+			script      strings.Builder // Рядки незавершеного блоку { }
+			start       int             // Номер першого рядка script
+			depth       int             // Вкладеність блоків у script
+		)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				close(closed)
+				return
+			}
+
+			first := line + 1 // Номер першого рядка повідомлення
+			scanner := newScanner(strings.NewReader(string(data)))
+			for scanner.Scan() {
+				line++
+				text := scanner.Text()
+				if depth == 0 && strings.TrimSpace(text) == "" {
+					continue
+				}
+
+				// Рядки блоку repeat, for чи def накопичуються, доки не закриються всі його дужки,
+				// і виконуються разом як один скрипт.
+				if script.Len() == 0 {
+					start = line
+				}
+				script.WriteString(text)
+				script.WriteByte('\n')
+				if depth += blockDepth(text); depth > 0 {
+					continue
+				}
+				text, depth = script.String(), 0
+				script.Reset()
+
+				// Як і в HttpHandler, скрипт, для якого в черзі немає місця, не виконується.
+				slot, err := loop.Reserve(r.Context())
+				if err != nil {
+					pending <- pendingMessage{msg: WsMessage{Type: "error", Line: start, Error: err.Error()}, ready: closedChan}
+					continue
+				}
+				res, err := p.run(strings.NewReader(text))
+				if err != nil {
+					slot.Release()
+					shiftLines(err, start-1)
+					pending <- pendingMessage{msg: WsMessage{Type: "error", Line: start, Error: err.Error()}, ready: closedChan}
+					continue
+				}
+				if p.Animating() {
+					loop.Animate(p)
+				}
+				if !isUpdate(res.ops) {
+					slot.Release()
+					continue
+				}
+
+				frame++
+				ready := make(chan struct{})
+				if _, err := p.post(loop, slot, res); err != nil {
+					pending <- pendingMessage{msg: WsMessage{Type: "error", Line: line, Error: err.Error()}, ready: closedChan}
+					continue
+				}
+				if err := loop.Notify(func() { close(ready) }); err != nil {
+					pending <- pendingMessage{msg: WsMessage{Type: "error", Line: line, Error: err.Error()}, ready: closedChan}
+					continue
+				}
+				pending <- pendingMessage{msg: WsMessage{Type: "ack", Line: line, Frame: frame}, ready: ready}
+			}
+			// Решта повідомлення після занадто довгого рядка не виконується, а незавершений блок відкидається.
+			if err := scanner.Err(); err != nil {
+				failed := line + 1
+				script.Reset()
+				depth = 0
+				pending <- pendingMessage{msg: WsMessage{Type: "error", Line: failed, Error: scanError(failed, err).Error()}, ready: closedChan}
+				// Нумерація рядків наступних повідомлень враховує всі рядки цього.
+				line = first + strings.Count(strings.TrimSuffix(string(data), "\n"), "\n")
+			}
+		}
+	})
+}
+
+// shiftLines зсуває номери рядків помилок скрипта err на offset, щоб вони відповідали рядкам з'єднання.
+func shiftLines(err error, offset int) {
+	var errs ParseErrors
+	if errors.As(err, &errs) {
+		for _, e := range errs {
+			e.Line += offset
+		}
+	}
+}
+
+// writeMessages надсилає повідомлення клієнту по черзі, дочікуючись готовності кожного з них.
+// Завершується, коли канал pending закрито або з'єднання розірвано.
+func writeMessages(conn *websocket.Conn, pending <-chan pendingMessage, closed <-chan struct{}) {
+	for m := range pending {
+		select {
+		case <-m.ready:
+		case <-closed:
+			continue
+		}
+		if err := conn.WriteJSON(m.msg); err != nil {
+			log.Printf("Cannot write WebSocket message: %s", err)
+		}
+	}
+}
+
+// isUpdate перевіряє, чи завершується список операцій командою update.
+func isUpdate(ops []painter.Operation) bool {
+	return len(ops) > 0 && ops[len(ops)-1] == painter.UpdateOp
+}
@@ -0,0 +1,118 @@
+package lang
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/shiny/screen"
+)
+
+// countingReceiver рахує кадри, передані циклом подій.
+type countingReceiver struct {
+	frames atomic.Int32
+}
+
+func (r *countingReceiver) Update(screen.Texture) {
+	r.frames.Add(1)
+}
+
+func TestWebSocketHandler(t *testing.T) {
+	receiver := &countingReceiver{}
+	loop := &painter.Loop{Receiver: receiver}
+	loop.Start(context.Background(), painter.HeadlessScreen{})
+	defer loop.StopAndWait()
+
+	server := httptest.NewServer(WebSocketHandler(loop, &Parser{}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("fill white\nfigure id=a 0.5 0.5\nupdate")))
+
+	var msg WsMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, WsMessage{Type: "ack", Line: 3, Frame: 1}, msg)
+	assert.EqualValues(t, 1, receiver.frames.Load(), "ack is sent only after the frame reached the receiver")
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("move b 0.1 0.1")))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("move a 0.1 0.1\nupdate")))
+
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "error", msg.Type)
+	assert.Equal(t, 4, msg.Line)
+	assert.Contains(t, msg.Error, "unknown object")
+
+	msg = WsMessage{}
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, WsMessage{Type: "ack", Line: 6, Frame: 2}, msg)
+	assert.EqualValues(t, 2, receiver.frames.Load())
+}
+
+func TestWebSocketHandlerBlock(t *testing.T) {
+	receiver := &countingReceiver{}
+	loop := &painter.Loop{Receiver: receiver}
+	loop.Start(context.Background(), painter.HeadlessScreen{})
+	defer loop.StopAndWait()
+
+	server := httptest.NewServer(WebSocketHandler(loop, &Parser{}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("repeat 2 {\n  figure 0.1 0.1")))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("  update\n}")))
+
+	var msg WsMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, WsMessage{Type: "ack", Line: 4, Frame: 1}, msg)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("for i in 0..2 {\n  move nope 0.1 0.1\n}")))
+	msg = WsMessage{}
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "error", msg.Type)
+	assert.Equal(t, 5, msg.Line)
+	assert.Contains(t, msg.Error, "line 6", "error lines are numbered within the connection")
+	assert.Contains(t, msg.Error, "unknown object")
+}
+
+func TestWebSocketHandlerLongLine(t *testing.T) {
+	loop := &painter.Loop{Receiver: &countingReceiver{}}
+	loop.Start(context.Background(), painter.HeadlessScreen{})
+	defer loop.StopAndWait()
+
+	server := httptest.NewServer(WebSocketHandler(loop, &Parser{}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+
+	long := strings.Repeat("#", maxLineSize+1)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("fill red\n"+long+"\nupdate")))
+
+	var msg WsMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "error", msg.Type)
+	assert.Equal(t, 2, msg.Line)
+	assert.Contains(t, msg.Error, "line is longer than")
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("update")))
+	msg = WsMessage{}
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, WsMessage{Type: "ack", Line: 4, Frame: 1}, msg)
+}
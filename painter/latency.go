@@ -0,0 +1,87 @@
+package painter
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// LatencyBuckets — верхні межі інтервалів гістограми часу виконання операцій (див. Loop.OpLatency).
+var LatencyBuckets = []time.Duration{
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+}
+
+// OpLatency — розподіл часу виконання операцій одного типу.
+type OpLatency struct {
+	// Buckets[i] — кількість операцій, виконаних не довше за LatencyBuckets[i].
+	// Як і в гістограмах Prometheus, кожен інтервал включає всі менші.
+	Buckets []uint64
+	// Count — кількість виконаних операцій.
+	Count uint64
+	// Sum — сумарний час їх виконання.
+	Sum time.Duration
+}
+
+// OpLatency повертає розподіл часу виконання операцій циклу за назвою їх типу (наприклад, "FigureOp").
+// Списки операцій та інші Composite не враховуються самі по собі: враховується кожна вкладена операція.
+// Безпечно викликати з будь-якої горутини.
+func (l *Loop) OpLatency() map[string]OpLatency {
+	l.latency.mu.Lock()
+	defer l.latency.mu.Unlock()
+
+	res := make(map[string]OpLatency, len(l.latency.ops))
+	for name, h := range l.latency.ops {
+		res[name] = OpLatency{Buckets: append([]uint64(nil), h.Buckets...), Count: h.Count, Sum: h.Sum}
+	}
+	return res
+}
+
+// latencies накопичує гістограми часу виконання операцій циклу за типом операції.
+type latencies struct {
+	mu  sync.Mutex
+	ops map[string]*OpLatency
+}
+
+// observe враховує операцію op, виконану за час d.
+func (ls *latencies) observe(op Operation, d time.Duration) {
+	name := opName(op)
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	h := ls.ops[name]
+	if h == nil {
+		if ls.ops == nil {
+			ls.ops = make(map[string]*OpLatency)
+		}
+		h = &OpLatency{Buckets: make([]uint64, len(LatencyBuckets))}
+		ls.ops[name] = h
+	}
+	for i, le := range LatencyBuckets {
+		if d <= le {
+			h.Buckets[i]++
+		}
+	}
+	h.Count++
+	h.Sum += d
+}
+
+// opName повертає назву типу операції без вказівника та пакета.
+func opName(op Operation) string {
+	t := reflect.TypeOf(op)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
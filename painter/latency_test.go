@@ -0,0 +1,37 @@
+package painter
+
+import (
+	"context"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/shiny/screen"
+)
+
+func TestOpLatency(t *testing.T) {
+	loop := &Loop{Receiver: &frameReceiver{frames: make(chan *image.RGBA, 1)}}
+	loop.Start(context.Background(), HeadlessScreen{})
+	defer loop.StopAndWait()
+
+	slow := OperationFunc(func(screen.Texture) { time.Sleep(30 * time.Millisecond) })
+	require.NoError(t, loop.PostAndWait(context.Background(), slow))
+	require.NoError(t, loop.PostAndWait(context.Background(), &FigureOp{X: 10, Y: 10}))
+	require.NoError(t, loop.PostAndWait(context.Background(), OperationList{&FigureOp{X: 20, Y: 20}, OperationList{UpdateOp}}))
+
+	latency := loop.OpLatency()
+	require.Contains(t, latency, "OperationFunc")
+	require.Contains(t, latency, "FigureOp", "tracked operations are counted by their own type")
+	assert.NotContains(t, latency, "OperationList", "lists are counted by their elements")
+	assert.Equal(t, uint64(1), latency["updateOp"].Count)
+
+	h := latency["OperationFunc"]
+	assert.Equal(t, uint64(1), h.Count)
+	assert.GreaterOrEqual(t, h.Sum, 30*time.Millisecond)
+	for i, le := range LatencyBuckets {
+		assert.Equal(t, le >= h.Sum, h.Buckets[i] == 1, "bucket %s", le)
+	}
+	assert.Equal(t, uint64(2), latency["FigureOp"].Count)
+}
@@ -1,9 +1,14 @@
 package painter
 
 import (
+	"context"
+	"errors"
 	"image"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/roman-mazur/architecture-lab-3/ui"
 	"golang.org/x/exp/shiny/screen"
 )
 
@@ -12,119 +17,587 @@ type Receiver interface {
 	Update(t screen.Texture)
 }
 
+// ErrStopped повертається з Post, якщо цикл подій вже зупинено або він зупиняється.
+var ErrStopped = errors.New("painter loop is stopped")
+
 // Loop реалізує цикл подій для формування текстури отриманої через виконання операцій отриманих з внутрішньої черги.
 type Loop struct {
 	Receiver Receiver
+	// FrameRate — частота кадрів анімацій (див. Animate); якщо не задано, використовується DefaultFrameRate.
+	FrameRate int
+	// MaxFPS обмежує кількість кадрів, що передаються у Receiver.Update за секунду; 0 означає без обмеження.
+	// Кадри, готові частіше, об'єднуються: у Receiver потрапляє лише останній з них.
+	MaxFPS int
+	// Capacity обмежує кількість операцій, що чекають у черзі; 0 означає без обмеження.
+	Capacity int
+	// Overflow визначає, що робить Post, коли черга заповнена; за замовчуванням Post чекає на місце.
+	Overflow OverflowPolicy
 	next screen.Texture
 	prev screen.Texture
-	stopReq bool
 	stopped chan struct{}
 	mq messageQueue
+	anim animators
+
+	ready       screen.Texture // Готовий кадр, що чекає на передачу у Receiver; використовується лише з MaxFPS
+	pending     bool           // Текстура ready містить кадр, ще не переданий у Receiver
+	presentedAt time.Time      // Час передачі останнього кадру
+	notify      []func()       // Функції Notify, що чекають на передачу кадру pending
+	waiting     []*Future      // Future операцій Track, що чекають на передачу кадру pending
+	stats       frameCounters
+	latency     latencies // Час виконання операцій за типом (див. OpLatency)
+	blender     bool      // Текстури циклу реалізують Blender (див. CanBlend)
+}
+
+// FrameStats містить лічильники кадрів циклу подій.
+type FrameStats struct {
+	// Presented — кількість кадрів, переданих у Receiver.Update.
+	Presented uint64 `json:"presented"`
+	// Merged — кількість готових кадрів, замінених новішим кадром до передачі через обмеження MaxFPS.
+	Merged uint64 `json:"merged"`
+	// Dropped — кількість кадрів, що не потрапили у Receiver: кадри анімацій, пропущені через зайнятий цикл
+	// чи заповнену чергу, та готовий кадр, відкинутий під час скасування контексту циклу.
+	Dropped uint64 `json:"dropped"`
+}
+
+type frameCounters struct {
+	presented, merged, dropped atomic.Uint64
+}
+
+// Stats повертає лічильники кадрів циклу подій. Безпечно викликати з будь-якої горутини.
+func (l *Loop) Stats() FrameStats {
+	return FrameStats{
+		Presented: l.stats.presented.Load(),
+		Merged:    l.stats.merged.Load(),
+		Dropped:   l.stats.dropped.Load(),
+	}
 }
 
-var size = image.Pt(400, 400)
+// size збігається з розміром вікна, оскільки lang.Parser масштабує координати скриптів саме до нього.
+var size = image.Pt(ui.WINDOW_SIZE, ui.WINDOW_SIZE)
 
 // Start запускає цикл подій. Цей метод потрібно запустити до того, як викликати на ньому будь-які інші методи.
-func (l *Loop) Start(s screen.Screen) {
+// Коли ctx скасовано, цикл завершується, а операції, що ще залишились у черзі, відкидаються.
+func (l *Loop) Start(ctx context.Context, s screen.Screen) {
 
 	l.next, _ = s.NewTexture(size)
 	l.prev, _ = s.NewTexture(size)
-	l.mq = messageQueue{}
+	_, l.blender = l.next.(Blender)
+
+	if l.MaxFPS > 0 {
+
+		l.ready, _ = s.NewTexture(size)
+
+	}
+
+	l.mq = messageQueue{capacity: l.Capacity, policy: l.Overflow}
+	l.stopped = make(chan struct{})
+
+	go l.mainEventLoop(ctx) // Запуск обробника подій у окремій горутині
+
+}
+
+// CanBlend повідомляє, чи підтримують текстури циклу режим змішування mode (див. painter.CanBlend).
+// Викликається після Start з будь-якої горутини.
+func (l *Loop) CanBlend(mode BlendMode) bool {
+
+	return mode == BlendOver || mode == BlendSrc || l.blender
 
-	go l.mainEventLoop() // Запуск обробника подій у окремій горутині
-	
 }
 
 // Основний цикл обробки подій
-func (l *Loop) mainEventLoop() {
+func (l *Loop) mainEventLoop(ctx context.Context) {
+
+	defer l.release()
 
 	for {
 
-		if op := l.mq.Pull(); op != nil {
+		// Готовий кадр передається, щойно минув інтервал MaxFPS; до того виконуються наступні операції,
+		// і якщо одна з них підготує новіший кадр, попередній буде об'єднано з ним.
+		var timer *time.Timer
+		if l.pending {
+
+			wait := time.Second/time.Duration(l.MaxFPS) - time.Since(l.presentedAt)
+			if wait <= 0 {
+
+				l.present()
+				continue
+
+			}
+
+			timer = time.NewTimer(wait)
+
+		}
+
+		op, ok := l.mq.Pull(ctx, timer)
+
+		if timer != nil {
+
+			timer.Stop()
+
+		}
+
+		if !ok {
+
+			// Під час StopAndWait і StopNow останній кадр передається, а при скасуванні ctx — відкидається.
+			if l.pending && ctx.Err() == nil {
+
+				l.present()
 
-			if update := op.Do(l.next); update {
+			} else if l.pending {
 
-				l.Receiver.Update(l.next)
-				l.next, l.prev = l.prev, l.next
+				l.stats.dropped.Add(1)
 
 			}
 
+			return
+
+		}
+
+		if op != nil {
+
+			l.do(op)
+
 		}
 
 	}
 
 }
 
-// Post додає нову операцію у внутрішню чергу.
-func (l *Loop) Post(op Operation) {
+// do виконує операцію op над текстурою next.
+func (l *Loop) do(op Operation) {
+
+	if n, ok := op.(notifyOp); ok {
+
+		if l.pending {
+
+			l.notify = append(l.notify, n)
+
+		} else {
+
+			n()
+
+		}
+
+		return
+
+	}
 
-	if op != nil {
+	var future *Future
+	if t, ok := op.(*trackedOp); ok {
 
-		l.mq.Push(op)
+		op, future = t.Operation, t.future
 
 	}
 
+	if update := l.exec(op); !update {
+
+		if s, ok := op.(Superseder); ok && s.Superseded() {
+
+			future.resolve(ErrSuperseded)
+
+		} else {
+
+			future.resolve(nil)
+
+		}
+
+		return
+
+	}
+
+	if l.ready == nil {
+
+		// Без обмеження MaxFPS кадр передається одразу.
+		l.ready, l.next = l.next, nil
+		l.present()
+		l.next, l.ready = l.ready, nil
+		future.resolve(nil)
+		return
+
+	}
+
+	if l.pending {
+
+		l.stats.merged.Add(1)
+
+	}
+
+	// Наступні операції малюють на іншій текстурі, тож готовий кадр не зміниться до передачі.
+	l.next, l.ready = l.ready, l.next
+	l.pending = true
+
+	if future != nil {
+
+		l.waiting = append(l.waiting, future)
+
+	}
+
+}
+
+// exec виконує op над текстурою next. Вкладені операції Composite виконуються по одній,
+// тож час виконання враховується для кожного типу операцій окремо (див. OpLatency).
+func (l *Loop) exec(op Operation) bool {
+
+	if c, ok := op.(Composite); ok {
+
+		return c.DoEach(l.exec)
+
+	}
+
+	start := time.Now()
+	update := op.Do(l.next)
+	l.latency.observe(op, time.Since(start))
+	return update
+
+}
+
+// present передає кадр ready у Receiver та викликає функції Notify, що на нього чекали.
+// Текстура prev з попереднім кадром, який Receiver вже не використовує, стає новою текстурою ready.
+func (l *Loop) present() {
+
+	l.Receiver.Update(l.ready)
+	l.ready, l.prev = l.prev, l.ready
+	l.pending, l.presentedAt = false, time.Now()
+	l.stats.presented.Add(1)
+
+	for _, f := range l.notify {
+
+		f()
+
+	}
+
+	l.notify = nil
+
+	for _, f := range l.waiting {
+
+		f.resolve(nil)
+
+	}
+
+	l.waiting = nil
+
+}
+
+// release відкидає невиконані операції, звільняє текстури та повідомляє про зупинку циклу.
+func (l *Loop) release() {
+
+	l.mq.Close(true)
+
+	// Кадр, на який чекають ці Future, вже не буде передано.
+	for _, f := range l.waiting {
+
+		f.resolve(ErrStopped)
+
+	}
+
+	l.waiting = nil
+
+	if l.next != nil {
+
+		l.next.Release()
+
+	}
+
+	if l.prev != nil {
+
+		l.prev.Release()
+
+	}
+
+	if l.ready != nil {
+
+		l.ready.Release()
+
+	}
+
+	close(l.stopped)
+
+}
+
+// Post додає нову операцію у внутрішню чергу. Повертає ErrStopped, якщо цикл вже не приймає операції.
+// Якщо черга заповнена, поводиться згідно з Overflow (див. Reserve).
+func (l *Loop) Post(op Operation) error {
+
+	if op == nil {
+
+		return nil
+
+	}
+
+	r, err := l.Reserve(context.Background())
+
+	if err != nil {
+
+		return err
+
+	}
+
+	return r.Post(op)
+
+}
+
+// PostUrgent додає операцію у чергу термінових операцій, які виконуються раніше за звичайні операції,
+// що вже чекають у черзі. Операції кожної черги виконуються в порядку додавання; після urgentBurst
+// термінових операцій поспіль виконується одна звичайна, тож звичайні операції не чекають безкінечно.
+// Ємність Capacity спільна для обох черг.
+func (l *Loop) PostUrgent(op Operation) error {
+
+	if op == nil {
+
+		return nil
+
+	}
+
+	r, err := l.Reserve(context.Background())
+
+	if err != nil {
+
+		return err
+
+	}
+
+	return r.PostUrgent(op)
+
+}
+
+// Notify викликає f у горутині циклу подій, коли всі звичайні операції, додані раніше, виконано,
+// а підготовлений ними кадр (якщо він є) передано у Receiver.Update. Якщо цикл зупиниться раніше, f не викликається.
+func (l *Loop) Notify(f func()) error {
+
+	// Службова операція не займає місця в черзі, щоб її не затримала політика Overflow.
+	return l.mq.add(notifyOp(f), false, false)
+
+}
+
+// notifyOp — операція Notify, яку цикл подій виконує окремо від решти операцій.
+type notifyOp func()
+
+func (op notifyOp) Do(screen.Texture) bool {
+
+	op()
+	return false
+
 }
 
 // StopAndWait сигналізує про необхідність завершити цикл та блокується до моменту його повної зупинки.
+// Нові операції після виклику відхиляються, а ті, що вже були у черзі, виконуються до кінця.
+// Щоб зупинка не чекала на всі операції в черзі, використовуйте StopNow.
 func (l *Loop) StopAndWait() {
 
-	l.Post(OperationFunc(func(screen.Texture) {
+	l.stop(false)
+
+}
+
+// StopNow зупиняє цикл, не чекаючи на операції в черзі: як і PostUrgent, запит на зупинку випереджає
+// звичайні операції. Операція, що вже виконується, завершується, а підготовлений кадр передається у Receiver,
+// тоді як решта операцій обох черг відкидається (Future таких операцій завершуються з ErrStopped).
+// На відміну від скасування контексту Start, StopNow не відкидає готовий кадр. Блокується до повної зупинки циклу.
+func (l *Loop) StopNow() {
+
+	l.stop(true)
+
+}
 
-		l.stopReq = true
+// stop закриває чергу (discard визначає, чи відкинути операції в ній) і чекає на зупинку циклу.
+func (l *Loop) stop(discard bool) {
 
-	}))
+	if l.stopped == nil {
 
+		return
+
+	}
+
+	l.mq.Close(discard)
 	<-l.stopped
 
 }
 
+// urgentBurst — скільки термінових операцій поспіль може виконати цикл, перш ніж виконати одну звичайну.
+// Завдяки цьому потік термінових операцій не блокує звичайні назавжди.
+const urgentBurst = 8
+
 // Реалізована черга подій
 // Структура черги повідомлень
 type messageQueue struct {
 	mu      sync.Mutex // Мьютекс для захисту конкурентного доступу
 	Queue   []Operation // Список операцій
+	Urgent  []Operation // Термінові операції, що виконуються раніше за Queue (див. Loop.PostUrgent)
+	burst   int // Кількість термінових операцій, виконаних поспіль
 	blocked chan struct{} // Канал, який блокує Pull, поки черга порожня
+	closed  bool // Черга більше не приймає нові операції
+
+	capacity int            // Максимальна кількість операцій у черзі; 0 означає без обмеження
+	policy   OverflowPolicy // Поведінка reserve, коли черга заповнена
+	reserved int            // Кількість зарезервованих місць (див. Reservation)
+	space    chan struct{}  // Канал, який блокує reserve, поки черга заповнена
+	dropped  uint64         // Кількість операцій, відкинутих політикою OverflowDropOldest
+	rejected uint64         // Кількість операцій, відхилених політикою OverflowReject
 }
 
-// Додавання операції в чергу
-func (mq *messageQueue) Push(op Operation) {
+// Додавання операції в чергу (urgent визначає чергу термінових операцій).
+// Якщо reserved дорівнює true, операція займає місце, раніше зарезервоване методом reserve.
+func (mq *messageQueue) add(op Operation, urgent, reserved bool) error {
 
 	mq.mu.Lock()
 
 	defer mq.mu.Unlock()
 
-	mq.Queue = append(mq.Queue, op)
+	if reserved {
 
-	if mq.blocked != nil {
+		mq.reserved--
 
-		close(mq.blocked)
-		mq.blocked = nil
+	}
+
+	if mq.closed {
+
+		mq.freeSpace()
+		return ErrStopped
+
+	}
+
+	// Політика OverflowDropOldest звільняє місце лише тоді, коли операцію справді додано, тож зарезервоване
+	// й звільнене без операції місце (наприклад, для скрипта з помилкою) не відкидає чужих операцій.
+	if reserved && mq.policy == OverflowDropOldest && mq.capacity > 0 {
+
+		for len(mq.Queue)+len(mq.Urgent) >= mq.capacity && mq.dropOldest() {
+		}
+
+	}
+
+	if urgent {
+
+		mq.Urgent = append(mq.Urgent, op)
+
+	} else {
+
+		mq.Queue = append(mq.Queue, op)
 
 	}
 
+	mq.wake()
+
+	return nil
+
 }
 
-// Витягування операції з черги (якщо черга порожня, то блокує)
-func (mq *messageQueue) Pull() Operation {
+// Витягування операції з черги (якщо черга порожня, то блокує).
+// Повертає false, якщо черга закрита і порожня або ctx скасовано.
+// Якщо timer не nil і спрацьовує раніше, ніж у черзі з'явиться операція, повертає nil та true.
+func (mq *messageQueue) Pull(ctx context.Context, timer *time.Timer) (Operation, bool) {
 
 	mq.mu.Lock()
 
 	defer mq.mu.Unlock()
 
-	for len(mq.Queue) == 0 {
+	for len(mq.Queue) == 0 && len(mq.Urgent) == 0 {
+
+		if mq.closed {
+
+			return nil, false
+
+		}
+
+		if mq.blocked == nil {
+
+			mq.blocked = make(chan struct{})
 
-		mq.blocked = make(chan struct{})
+		}
+
+		var timeout <-chan time.Time
+		if timer != nil {
+
+			timeout = timer.C
+
+		}
+
+		blocked := mq.blocked
 		mq.mu.Unlock()
-		<-mq.blocked
+
+		select {
+		case <-blocked:
+		case <-timeout:
+			mq.mu.Lock()
+			return nil, true
+		case <-ctx.Done():
+			mq.mu.Lock()
+			return nil, false
+		}
+
 		mq.mu.Lock()
 
 	}
 
+	if ctx.Err() != nil {
+
+		return nil, false
+
+	}
+
+	if len(mq.Urgent) > 0 && (mq.burst < urgentBurst || len(mq.Queue) == 0) {
+
+		op := mq.Urgent[0]
+		mq.Urgent[0] = nil
+		mq.Urgent = mq.Urgent[1:]
+		mq.burst++
+		mq.freeSpace()
+
+		return op, true
+
+	}
+
 	op := mq.Queue[0]
 	mq.Queue[0] = nil
 	mq.Queue = mq.Queue[1:]
+	mq.burst = 0
+	mq.freeSpace()
 
-	return op
+	return op, true
 
-}
\ No newline at end of file
+}
+
+// Close забороняє додавати нові операції. Якщо discard дорівнює true, операції, що залишились у черзі, відкидаються.
+func (mq *messageQueue) Close(discard bool) {
+
+	mq.mu.Lock()
+
+	defer mq.mu.Unlock()
+
+	mq.closed = true
+
+	if discard {
+
+		// Відкинуті операції вже не буде виконано, тож їх Future завершуються одразу.
+		for _, lane := range [][]Operation{mq.Queue, mq.Urgent} {
+
+			for _, op := range lane {
+
+				if t, ok := op.(*trackedOp); ok {
+
+					t.future.resolve(ErrStopped)
+
+				}
+
+			}
+
+		}
+
+		mq.Queue, mq.Urgent = nil, nil
+
+	}
+
+	mq.wake()
+	mq.freeSpace()
+
+}
+
+// wake розблоковує Pull, що очікує на нові операції. Викликається під mq.mu.
+func (mq *messageQueue) wake() {
+
+	if mq.blocked != nil {
+
+		close(mq.blocked)
+		mq.blocked = nil
+
+	}
+
+}
@@ -1,6 +1,7 @@
 package painter
 
 import (
+	"context"
 	"image"
 	"image/color"
 	"image/draw"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/exp/shiny/screen"
 )
 
@@ -65,7 +67,7 @@ func (m *Mock) Do(t screen.Texture) bool {
 // newLoopWithMocks створює підготовлений тестовий цикл Loop з усіма необхідними моками.
 // Повертає: цикл рендерингу, мок текстури, мок приймача (Receiver) та мок екрану (screen)
 func newLoopWithMocks(t *testing.T) (*Loop, *Mock, *Mock, *Mock) {
-	textureSize := image.Pt(400, 400)
+	textureSize := image.Pt(800, 800)
 
 	textureMock := new(Mock)
 	receiverMock := new(Mock)
@@ -74,10 +76,12 @@ func newLoopWithMocks(t *testing.T) (*Loop, *Mock, *Mock, *Mock) {
 	screenMock.On("NewTexture", textureSize).Return(textureMock, nil)
 	receiverMock.On("Update", textureMock).Return()
 
-	loop := &Loop{Receiver: receiverMock}
-	loop.Start(screenMock)
-
 	textureMock.On("Bounds").Return(image.Rectangle{})
+	textureMock.On("Release").Return()
+
+	loop := &Loop{Receiver: receiverMock}
+	loop.Start(context.Background(), screenMock)
+	t.Cleanup(loop.StopAndWait)
 
 	return loop, textureMock, receiverMock, screenMock
 }
@@ -100,7 +104,7 @@ func TestPostSingleSuccess(t *testing.T) {
 
 	op.AssertCalled(t, "Do", textureMock)
 	receiverMock.AssertCalled(t, "Update", textureMock)
-	screenMock.AssertCalled(t, "NewTexture", image.Pt(400, 400))
+	screenMock.AssertCalled(t, "NewTexture", image.Pt(800, 800))
 	assert.Empty(t, loop.mq.Queue)
 }
 
@@ -119,7 +123,7 @@ func TestPostMultipleSuccess(t *testing.T) {
 	op1.AssertCalled(t, "Do", textureMock)
 	op2.AssertCalled(t, "Do", textureMock)
 	receiverMock.AssertCalled(t, "Update", textureMock)
-	screenMock.AssertCalled(t, "NewTexture", image.Pt(400, 400))
+	screenMock.AssertCalled(t, "NewTexture", image.Pt(800, 800))
 	assert.Empty(t, loop.mq.Queue)
 }
 
@@ -134,6 +138,227 @@ func TestPostSingleFailure(t *testing.T) {
 
 	op.AssertCalled(t, "Do", textureMock)
 	receiverMock.AssertNotCalled(t, "Update", textureMock)
-	screenMock.AssertCalled(t, "NewTexture", image.Pt(400, 400))
+	screenMock.AssertCalled(t, "NewTexture", image.Pt(800, 800))
 	assert.Empty(t, loop.mq.Queue)
 }
+
+func TestStopAndWaitDrainsQueue(t *testing.T) {
+	loop, textureMock, _, _ := newLoopWithMocks(t)
+
+	block := make(chan struct{})
+	loop.Post(OperationFunc(func(screen.Texture) { <-block }))
+
+	op := new(Mock)
+	op.On("Do", textureMock).Return(false)
+	loop.Post(op)
+
+	done := make(chan struct{})
+	go func() {
+		loop.StopAndWait()
+		close(done)
+	}()
+	close(block)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("StopAndWait did not return")
+	}
+
+	op.AssertCalled(t, "Do", textureMock)
+	textureMock.AssertNumberOfCalls(t, "Release", 2)
+}
+
+func TestStopNowSkipsQueue(t *testing.T) {
+	receiver := &frameReceiver{frames: make(chan *image.RGBA, 4)}
+	loop := &Loop{Receiver: receiver, MaxFPS: 1}
+	loop.Start(context.Background(), HeadlessScreen{})
+
+	require.NoError(t, loop.PostAndWait(context.Background(), UpdateOp))
+	block, started := make(chan struct{}), make(chan struct{})
+	loop.Post(OperationList{&FillOp{Color: color.White}, UpdateOp})
+	loop.Post(OperationFunc(func(screen.Texture) {
+		close(started)
+		<-block
+	}))
+	<-started
+	executed := 0
+	for i := 0; i < 1000; i++ {
+		loop.Post(OperationFunc(func(screen.Texture) { executed++ }))
+	}
+	op, future := loop.Track(UpdateOp)
+	loop.Post(op)
+
+	done := make(chan struct{})
+	go func() {
+		loop.StopNow()
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return loop.Post(UpdateOp) != nil }, time.Second, time.Millisecond)
+	close(block)
+
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("StopNow waited for the queued operations")
+	}
+	assert.Zero(t, executed, "operations queued behind the stop are discarded")
+	assert.ErrorIs(t, future.Wait(context.Background()), ErrStopped)
+	assert.Equal(t, uint64(2), loop.Stats().Presented, "the pending frame is still presented")
+}
+
+func TestPostAfterStop(t *testing.T) {
+	loop, _, _, _ := newLoopWithMocks(t)
+
+	loop.StopAndWait()
+
+	assert.ErrorIs(t, loop.Post(UpdateOp), ErrStopped)
+}
+
+func TestContextCancelRejectsQueued(t *testing.T) {
+	textureMock := new(Mock)
+	screenMock := new(Mock)
+	screenMock.On("NewTexture", image.Pt(800, 800)).Return(textureMock, nil)
+	textureMock.On("Release").Return()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	loop := &Loop{Receiver: new(Mock)}
+	loop.Start(ctx, screenMock)
+
+	block := make(chan struct{})
+	loop.Post(OperationFunc(func(screen.Texture) { <-block }))
+
+	op := new(Mock)
+	loop.Post(op)
+
+	cancel()
+	close(block)
+	loop.StopAndWait()
+
+	op.AssertNotCalled(t, "Do", textureMock)
+	assert.Empty(t, loop.mq.Queue)
+	assert.ErrorIs(t, loop.Post(op), ErrStopped)
+	textureMock.AssertNumberOfCalls(t, "Release", 2)
+}
+
+func TestMaxFPSMergesUpdates(t *testing.T) {
+	textureMock := new(Mock)
+	receiverMock := new(Mock)
+	screenMock := new(Mock)
+	screenMock.On("NewTexture", image.Pt(800, 800)).Return(textureMock, nil)
+	textureMock.On("Release").Return()
+
+	var updates []time.Time
+	receiverMock.On("Update", textureMock).Run(func(mock.Arguments) { updates = append(updates, time.Now()) }).Return()
+
+	loop := &Loop{Receiver: receiverMock, MaxFPS: 20}
+	loop.Start(context.Background(), screenMock)
+
+	block := make(chan struct{})
+	loop.Post(OperationFunc(func(screen.Texture) { <-block }))
+	for i := 0; i < 5; i++ {
+		loop.Post(UpdateOp)
+	}
+	notified := make(chan FrameStats, 1)
+	loop.Notify(func() { notified <- loop.Stats() })
+	close(block)
+
+	select {
+	case stats := <-notified:
+		assert.Equal(t, FrameStats{Presented: 2, Merged: 3}, stats, "Notify runs after the merged frame is presented")
+	case <-time.After(time.Second):
+		t.Fatal("Notify was not called")
+	}
+
+	loop.Post(UpdateOp)
+	loop.StopAndWait()
+	assert.Equal(t, FrameStats{Presented: 3, Merged: 3}, loop.Stats(), "StopAndWait presents the pending frame")
+	textureMock.AssertNumberOfCalls(t, "Release", 3)
+	require.Len(t, updates, 3)
+	assert.GreaterOrEqual(t, updates[1].Sub(updates[0]), 50*time.Millisecond)
+}
+
+func TestMaxFPSPresentsCompleteFrames(t *testing.T) {
+	loop := &Loop{Receiver: new(Mock), MaxFPS: 50}
+	receiver := loop.Receiver.(*Mock)
+	receiver.On("Update", mock.Anything).Return()
+	loop.Start(context.Background(), HeadlessScreen{})
+	defer loop.StopAndWait()
+
+	red, blue := color.RGBA{R: 0xff, A: 0xff}, color.RGBA{B: 0xff, A: 0xff}
+	block := make(chan struct{})
+	loop.Post(UpdateOp)
+	loop.Post(OperationFunc(func(screen.Texture) { <-block }))
+	loop.Post(OperationList{&FillOp{Color: red}, UpdateOp})
+	loop.Post(&FillOp{Color: blue})
+	presented := make(chan color.RGBA, 1)
+	loop.Notify(func() {
+		presented <- loop.prev.(*ImageTexture).RGBA().RGBAAt(0, 0)
+	})
+	close(block)
+
+	assert.Equal(t, red, <-presented, "operations after a pending frame do not change it")
+}
+
+// pullAll забирає з черги всі операції, повертаючи їх у порядку виконання.
+func pullAll(mq *messageQueue) []Operation {
+	mq.Close(false)
+	var ops []Operation
+	for {
+		op, ok := mq.Pull(context.Background(), nil)
+		if !ok {
+			return ops
+		}
+		ops = append(ops, op)
+	}
+}
+
+// namedOp — операція, яку можна впізнати у результатах pullAll.
+type namedOp string
+
+func (namedOp) Do(screen.Texture) bool { return false }
+
+func TestMessageQueueServesUrgentFirst(t *testing.T) {
+	var mq messageQueue
+	for _, name := range []namedOp{"n1", "n2", "n3"} {
+		require.NoError(t, mq.add(name, false, false))
+	}
+	require.NoError(t, mq.add(namedOp("u1"), true, false))
+	require.NoError(t, mq.add(namedOp("u2"), true, false))
+
+	assert.Equal(t, []Operation{namedOp("u1"), namedOp("u2"), namedOp("n1"), namedOp("n2"), namedOp("n3")}, pullAll(&mq))
+}
+
+func TestMessageQueueDoesNotStarveNormalLane(t *testing.T) {
+	var mq messageQueue
+	require.NoError(t, mq.add(namedOp("n1"), false, false))
+	require.NoError(t, mq.add(namedOp("n2"), false, false))
+	for i := 0; i < 2*urgentBurst+4; i++ {
+		require.NoError(t, mq.add(namedOp("u"), true, false))
+	}
+
+	var order []namedOp
+	for _, op := range pullAll(&mq) {
+		order = append(order, op.(namedOp))
+	}
+	require.Len(t, order, 2*urgentBurst+6)
+	assert.Equal(t, namedOp("n1"), order[urgentBurst])
+	assert.Equal(t, namedOp("n2"), order[2*urgentBurst+1])
+}
+
+func TestPostUrgent(t *testing.T) {
+	loop := &Loop{Receiver: new(Mock)}
+	loop.Start(context.Background(), HeadlessScreen{})
+
+	var order []string
+	block := make(chan struct{})
+	loop.Post(OperationFunc(func(screen.Texture) { <-block }))
+	loop.Post(OperationFunc(func(screen.Texture) { order = append(order, "move") }))
+	loop.Post(OperationFunc(func(screen.Texture) { order = append(order, "move") }))
+	loop.PostUrgent(OperationFunc(func(screen.Texture) { order = append(order, "reset") }))
+	close(block)
+	loop.StopAndWait()
+
+	assert.Equal(t, []string{"reset", "move", "move"}, order)
+	assert.ErrorIs(t, loop.PostUrgent(UpdateOp), ErrStopped)
+}
@@ -18,12 +18,25 @@ type Operation interface {
 type OperationList []Operation
 
 func (ol OperationList) Do(t screen.Texture) (ready bool) {
+	return ol.DoEach(func(o Operation) bool { return o.Do(t) })
+}
+
+// DoEach реалізує Composite.
+func (ol OperationList) DoEach(do func(Operation) bool) (ready bool) {
 	for _, o := range ol {
-		ready = o.Do(t) || ready
+		ready = do(o) || ready
 	}
 	return
 }
 
+// Composite реалізують операції, що складаються з інших операцій. DoEach робить те саме, що й Do, але замість
+// виконання кожної вкладеної операції передає її у do. Так цикл подій вимірює час виконання кожного типу
+// операцій окремо, а не загальний час списку (див. Loop.OpLatency).
+type Composite interface {
+	Operation
+	DoEach(do func(Operation) bool) bool
+}
+
 // UpdateOp операція, яка не змінює текстуру, але сигналізує, що текстуру потрібно розглядати як готову.
 var UpdateOp = updateOp{}
 
@@ -39,26 +52,34 @@ func (f OperationFunc) Do(t screen.Texture) bool {
 	return false
 }
 
-// WhiteFill зафарбовує тестуру у білий колір. Може бути викоистана як Operation через OperationFunc(WhiteFill).
-func WhiteFill(t screen.Texture) {
-	t.Fill(t.Bounds(), color.White, screen.Src)
+// FillOp зафарбовує всю текстуру кольором Color. Використовується як фон сцени.
+type FillOp struct {
+	Color color.Color
 }
 
-// GreenFill зафарбовує тестуру у зелений колір. Може бути викоистана як Operation через OperationFunc(GreenFill).
-func GreenFill(t screen.Texture) {
-	t.Fill(t.Bounds(), color.RGBA{G: 0xff, A: 0xff}, screen.Src)
+// Do виконує операцію на об'єкті FillOp, зафарбовуючи всю текстуру.
+func (op *FillOp) Do(t screen.Texture) bool {
+	t.Fill(t.Bounds(), colorOr(op.Color, color.Black), screen.Src)
+	return false
 }
 
+// BgRectOp малює прямокутник кольору Color (за замовчуванням чорного), змішуючи його з фоном у режимі Blend.
 type BgRectOp struct {
 	X1 int
 	Y1 int
 	X2 int
 	Y2 int
+	Color color.Color
+	Blend BlendMode
 }
 
+// FigureOp малює T-образну фігуру кольору Color (за замовчуванням FigureColor) з центром у точці (X, Y),
+// змішуючи її з фоном у режимі Blend.
 type FigureOp struct {
 	X int
 	Y int
+	Color color.Color
+	Blend BlendMode
 }
 
 type MoveOp struct {
@@ -67,10 +88,10 @@ type MoveOp struct {
 	Figures []*FigureOp
 }
 
-// Do виконує операцію на об'єкті BgRectOp, заповнюючи прямокутник чорним кольором на переданому текстурному об'єкті
+// Do виконує операцію на об'єкті BgRectOp, заповнюючи прямокутник його кольором на переданому текстурному об'єкті
 func (op *BgRectOp) Do(t screen.Texture) bool {
 
-	t.Fill(image.Rect(op.X1, op.Y1, op.X2, op.Y2), color.RGBA{0, 0, 0, 255}, screen.Src)
+	fill(t, image.Rect(op.X1, op.Y1, op.X2, op.Y2), colorOr(op.Color, color.Black), op.Blend)
 
 	return false
 
@@ -79,13 +100,49 @@ func (op *BgRectOp) Do(t screen.Texture) bool {
 // Do виконує операцію на об'єкті FigureOp, малюючи T-образну фігуру на текстурі.
 func (op *FigureOp) Do(t screen.Texture) bool {
 
-	t.Fill(image.Rect(op.X-60, op.Y-40, op.X+60, op.Y), color.RGBA{0, 0, 255, 255}, draw.Src)
-	t.Fill(image.Rect(op.X-20, op.Y, op.X+20, op.Y+40), color.RGBA{0, 0, 255, 255}, draw.Src)
+	c := colorOr(op.Color, FigureColor)
+	fill(t, image.Rect(op.X-60, op.Y-40, op.X+60, op.Y), c, op.Blend)
+	fill(t, image.Rect(op.X-20, op.Y, op.X+20, op.Y+40), c, op.Blend)
 
 	return false
 
 }
 
+// Translate повертає копію прямокутника, зміщену на (dx, dy).
+func (op *BgRectOp) Translate(dx, dy int) Shape {
+
+	moved := *op
+	moved.X1, moved.Y1 = op.X1+dx, op.Y1+dy
+	moved.X2, moved.Y2 = op.X2+dx, op.Y2+dy
+
+	return &moved
+
+}
+
+// Translate повертає копію фігури, зміщену на (dx, dy).
+func (op *FigureOp) Translate(dx, dy int) Shape {
+
+	moved := *op
+	moved.X, moved.Y = op.X+dx, op.Y+dy
+
+	return &moved
+
+}
+
+// Anchor повертає лівий верхній кут прямокутника.
+func (op *BgRectOp) Anchor() image.Point {
+
+	return image.Pt(op.X1, op.Y1)
+
+}
+
+// Anchor повертає центр фігури.
+func (op *FigureOp) Anchor() image.Point {
+
+	return image.Pt(op.X, op.Y)
+
+}
+
 // Do виконує операцію переміщення всіх фігур FigureOp на екран з вказаними зміщеннями по осях X та Y.
 func (op *MoveOp) Do(t screen.Texture) bool {
 
@@ -106,3 +163,19 @@ func Reset(t screen.Texture) {
 	t.Fill(t.Bounds(), color.RGBA{0, 0, 0, 255}, draw.Src)
 
 }
+
+// FigureColor — колір фігур, для яких колір не задано.
+var FigureColor color.Color = color.RGBA{0, 0, 255, 255}
+
+// colorOr повертає c, або def, якщо колір не задано.
+func colorOr(c, def color.Color) color.Color {
+
+	if c == nil {
+
+		return def
+
+	}
+
+	return c
+
+}
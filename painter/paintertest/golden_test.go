@@ -0,0 +1,41 @@
+package paintertest
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGolden рендерить кожен скрипт testdata/*.painter і порівнює результат з testdata/<назва>.png.
+// Для оновлення еталонів: go test ./painter/paintertest -update
+func TestGolden(t *testing.T) {
+	scripts, err := filepath.Glob(filepath.Join("testdata", "*.painter"))
+	require.NoError(t, err)
+	require.NotEmpty(t, scripts)
+
+	for _, script := range scripts {
+		name := strings.TrimSuffix(filepath.Base(script), filepath.Ext(script))
+		t.Run(name, func(t *testing.T) {
+			got, err := RenderFile(script)
+			require.NoError(t, err)
+			AssertGolden(t, strings.TrimSuffix(script, filepath.Ext(script))+".png", got)
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	want := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	got := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	got.SetRGBA(1, 2, color.RGBA{B: 255, A: 255})
+
+	n, diff := Diff(got, want)
+
+	assert.Equal(t, 1, n)
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, diff.RGBAAt(1, 2))
+	assert.NotEqual(t, color.RGBA{R: 255, A: 255}, diff.RGBAAt(0, 0))
+}
@@ -0,0 +1,157 @@
+// Package paintertest містить допоміжні засоби для тестування рендерингу скриптів painter
+// без графічного вікна та порівняння результату з еталонними (golden) зображеннями.
+package paintertest
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/roman-mazur/architecture-lab-3/painter/lang"
+	"golang.org/x/exp/shiny/screen"
+)
+
+// Update вмикає режим перезапису еталонних зображень: go test ./... -update
+var Update = flag.Bool("update", false, "rewrite golden images instead of comparing against them")
+
+// FailedDir — каталог, у який записуються фактичні кадри та зображення різниці для тестів, що впали.
+const FailedDir = "failed"
+
+// frameReceiver зберігає копію кадру, переданого циклом подій.
+type frameReceiver struct {
+	frames chan *image.RGBA
+}
+
+func (r *frameReceiver) Update(t screen.Texture) {
+	r.frames <- t.(*painter.ImageTexture).Snapshot()
+}
+
+// Render виконує скрипт через lang.Parser та painter.Loop на програмній текстурі і повертає отриманий кадр.
+func Render(script io.Reader) (*image.RGBA, error) {
+	var parser lang.Parser
+	ops, err := parser.Parse(script)
+	if err != nil {
+		return nil, err
+	}
+
+	receiver := &frameReceiver{frames: make(chan *image.RGBA, 1)}
+	loop := &painter.Loop{Receiver: receiver}
+	loop.Start(context.Background(), painter.HeadlessScreen{})
+	defer loop.StopAndWait()
+
+	if err := loop.Post(painter.OperationList(append(ops, painter.UpdateOp))); err != nil {
+		return nil, err
+	}
+
+	select {
+	case frame := <-receiver.frames:
+		return frame, nil
+	case <-time.After(5 * time.Second):
+		return nil, errors.New("timed out waiting for a frame")
+	}
+}
+
+// RenderFile виконує скрипт з файлу path.
+func RenderFile(path string) (*image.RGBA, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Render(f)
+}
+
+// Diff порівнює два зображення попіксельно. Повертає кількість пікселів, що відрізняються, та зображення різниці,
+// на якому співпадаючі пікселі показані блідими, а відмінні — червоними.
+func Diff(got, want image.Image) (int, *image.RGBA) {
+	bounds := want.Bounds().Union(got.Bounds())
+	diff := image.NewRGBA(bounds)
+	mismatched := 0
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			p := image.Pt(x, y)
+			g := color.RGBAModel.Convert(got.At(x, y)).(color.RGBA)
+			w := color.RGBAModel.Convert(want.At(x, y)).(color.RGBA)
+			if p.In(got.Bounds()) && p.In(want.Bounds()) && g == w {
+				gray := color.GrayModel.Convert(w).(color.Gray)
+				v := 192 + gray.Y/4
+				diff.SetRGBA(x, y, color.RGBA{R: v, G: v, B: v, A: 255})
+				continue
+			}
+			mismatched++
+			diff.SetRGBA(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+
+	return mismatched, diff
+}
+
+// AssertGolden порівнює кадр got з еталонним PNG файлом golden. У режимі Update еталон перезаписується.
+// Якщо зображення відрізняються, фактичний кадр та зображення різниці записуються у FailedDir поряд з еталоном.
+func AssertGolden(t testing.TB, golden string, got *image.RGBA) {
+	t.Helper()
+
+	if *Update {
+		if err := writePNG(golden, got); err != nil {
+			t.Fatalf("cannot update golden image %s: %s", golden, err)
+		}
+		return
+	}
+
+	want, err := readPNG(golden)
+	if err != nil {
+		t.Fatalf("cannot read golden image %s (run with -update to create it): %s", golden, err)
+	}
+
+	n, diff := Diff(got, want)
+	if n == 0 {
+		return
+	}
+
+	dir := filepath.Join(filepath.Dir(golden), FailedDir)
+	name := strings.TrimSuffix(filepath.Base(golden), filepath.Ext(golden))
+	actualPath := filepath.Join(dir, name+".actual.png")
+	diffPath := filepath.Join(dir, name+".diff.png")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("cannot create %s: %s", dir, err)
+	}
+	if err := writePNG(actualPath, got); err != nil {
+		t.Errorf("cannot write %s: %s", actualPath, err)
+	}
+	if err := writePNG(diffPath, diff); err != nil {
+		t.Errorf("cannot write %s: %s", diffPath, err)
+	}
+	t.Errorf("%d pixels differ from %s; see %s and %s", n, golden, actualPath, diffPath)
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,193 @@
+package painter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrQueueFull повертається з Post, якщо черга циклу подій заповнена, а Loop.Overflow дорівнює OverflowReject.
+var ErrQueueFull = errors.New("painter loop queue is full")
+
+// OverflowPolicy визначає, що відбувається з новою операцією, коли черга циклу подій заповнена (див. Loop.Capacity).
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = iota // Post чекає, доки в черзі з'явиться місце.
+	OverflowDropOldest                       // Найстаріша операція відкидається, щоб звільнити місце.
+	OverflowReject                           // Post повертає ErrQueueFull.
+)
+
+var overflowPolicyNames = [...]string{
+	OverflowBlock:      "block",
+	OverflowDropOldest: "drop-oldest",
+	OverflowReject:     "reject",
+}
+
+func (p OverflowPolicy) String() string {
+	if p >= 0 && int(p) < len(overflowPolicyNames) {
+		return overflowPolicyNames[p]
+	}
+	return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+}
+
+// ParseOverflowPolicy повертає політику переповнення черги за її назвою.
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	for p, n := range overflowPolicyNames {
+		if n == name {
+			return OverflowPolicy(p), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown overflow policy: %s", name)
+}
+
+// QueueStats описує стан черги циклу подій.
+type QueueStats struct {
+	// Len — кількість операцій, що чекають на виконання, разом із зарезервованими місцями.
+	Len int `json:"len"`
+	// Capacity — максимальна кількість операцій у черзі (Loop.Capacity); 0 означає без обмеження.
+	Capacity int `json:"capacity"`
+	// Dropped — кількість операцій, відкинутих політикою OverflowDropOldest.
+	Dropped uint64 `json:"dropped"`
+	// Rejected — кількість операцій, відхилених політикою OverflowReject.
+	Rejected uint64 `json:"rejected"`
+}
+
+// QueueStats повертає стан черги циклу подій. Безпечно викликати з будь-якої горутини.
+func (l *Loop) QueueStats() QueueStats {
+	l.mq.mu.Lock()
+	defer l.mq.mu.Unlock()
+	return QueueStats{
+		Len:      l.mq.len(),
+		Capacity: l.mq.capacity,
+		Dropped:  l.mq.dropped,
+		Rejected: l.mq.rejected,
+	}
+}
+
+// Reservation — місце в черзі циклу подій, зайняте до того, як операція готова.
+// Дозволяє перевірити, що черга прийме операцію, ще до її підготовки (наприклад, до виконання скрипта,
+// який змінює стан парсера). Зарезервоване місце потрібно використати методом Post чи PostUrgent або звільнити Release.
+type Reservation struct {
+	loop *Loop
+	used bool
+}
+
+// Reserve резервує місце в черзі відповідно до Loop.Overflow: чекає на місце (повертаючи помилку ctx, якщо ctx
+// скасовано раніше) або повертає ErrQueueFull. З OverflowDropOldest резервування завжди успішне, а найстаріша
+// операція відкидається лише тоді, коли у зарезервоване місце додають операцію, тож Release нічого не відкидає.
+// Якщо цикл зупинено, повертає ErrStopped.
+func (l *Loop) Reserve(ctx context.Context) (*Reservation, error) {
+	if err := l.mq.reserve(ctx); err != nil {
+		return nil, err
+	}
+	return &Reservation{loop: l}, nil
+}
+
+// Post додає операцію у зарезервоване місце черги (див. Loop.Post).
+func (r *Reservation) Post(op Operation) error {
+	return r.push(op, false)
+}
+
+// PostUrgent додає операцію у зарезервоване місце черги термінових операцій (див. Loop.PostUrgent).
+func (r *Reservation) PostUrgent(op Operation) error {
+	return r.push(op, true)
+}
+
+// Release звільняє зарезервоване місце, якщо його ще не використано.
+func (r *Reservation) Release() {
+	if r.used {
+		return
+	}
+	r.used = true
+	r.loop.mq.release()
+}
+
+func (r *Reservation) push(op Operation, urgent bool) error {
+	if r.used {
+		return errors.New("reservation is already used")
+	}
+	if op == nil {
+		r.Release()
+		return nil
+	}
+	r.used = true
+	return r.loop.mq.add(op, urgent, true)
+}
+
+// reserve займає місце в черзі, застосовуючи політику переповнення.
+func (mq *messageQueue) reserve(ctx context.Context) error {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	for {
+		if mq.closed {
+			return ErrStopped
+		}
+		// Для OverflowDropOldest місце звільняється під час додавання операції (див. add).
+		if mq.capacity <= 0 || mq.policy == OverflowDropOldest || mq.len() < mq.capacity {
+			mq.reserved++
+			return nil
+		}
+
+		switch mq.policy {
+		case OverflowReject:
+			mq.rejected++
+			return ErrQueueFull
+		default:
+			if mq.space == nil {
+				mq.space = make(chan struct{})
+			}
+			space := mq.space
+			mq.mu.Unlock()
+			select {
+			case <-space:
+			case <-ctx.Done():
+				mq.mu.Lock()
+				return ctx.Err()
+			}
+			mq.mu.Lock()
+		}
+	}
+}
+
+// release звільняє зарезервоване місце.
+func (mq *messageQueue) release() {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	mq.reserved--
+	mq.freeSpace()
+}
+
+// len повертає кількість зайнятих місць у черзі. Викликається під mq.mu.
+func (mq *messageQueue) len() int {
+	return len(mq.Queue) + len(mq.Urgent) + mq.reserved
+}
+
+// dropOldest відкидає найстарішу звичайну (або, якщо таких немає, термінову) операцію.
+// Службові операції не відкидаються, бо на них чекають інші горутини, тож якщо у черзі лише вони,
+// повертає false, і черга тимчасово перевищує ємність. Викликається під mq.mu.
+func (mq *messageQueue) dropOldest() bool {
+	for _, lane := range []*[]Operation{&mq.Queue, &mq.Urgent} {
+		for i, op := range *lane {
+			switch op := op.(type) {
+			case notifyOp, *frameOp:
+				continue
+			case *trackedOp:
+				op.future.resolve(ErrDropped)
+			}
+			*lane = append((*lane)[:i:i], (*lane)[i+1:]...)
+			mq.dropped++
+			return true
+		}
+	}
+	return false
+}
+
+// freeSpace розблоковує reserve, що очікує на місце в черзі. Викликається під mq.mu.
+func (mq *messageQueue) freeSpace() {
+	if mq.space != nil {
+		close(mq.space)
+		mq.space = nil
+	}
+}
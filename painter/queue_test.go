@@ -0,0 +1,93 @@
+package painter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBoundedLoop повертає незапущений цикл, черга якого вміщує capacity операцій.
+func newBoundedLoop(capacity int, policy OverflowPolicy) *Loop {
+	l := &Loop{Capacity: capacity, Overflow: policy}
+	l.mq = messageQueue{capacity: capacity, policy: policy}
+	return l
+}
+
+func TestOverflowReject(t *testing.T) {
+	l := newBoundedLoop(2, OverflowReject)
+	require.NoError(t, l.Post(namedOp("a")))
+	require.NoError(t, l.Post(namedOp("b")))
+
+	assert.ErrorIs(t, l.Post(namedOp("c")), ErrQueueFull)
+	_, err := l.Reserve(context.Background())
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.Equal(t, QueueStats{Len: 2, Capacity: 2, Rejected: 2}, l.QueueStats())
+
+	assert.Equal(t, []Operation{namedOp("a"), namedOp("b")}, pullAll(&l.mq))
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	l := newBoundedLoop(3, OverflowDropOldest)
+	require.NoError(t, l.Post(namedOp("a")))
+	require.NoError(t, l.mq.add(notifyOp(func() {}), false, false))
+	require.NoError(t, l.Post(namedOp("b")))
+	require.NoError(t, l.Post(namedOp("c")))
+
+	assert.Equal(t, QueueStats{Len: 3, Capacity: 3, Dropped: 1}, l.QueueStats())
+	ops := pullAll(&l.mq)
+	require.Len(t, ops, 3)
+	assert.IsType(t, notifyOp(nil), ops[0], "Notify is never dropped")
+	assert.Equal(t, []Operation{namedOp("b"), namedOp("c")}, ops[1:])
+}
+
+func TestOverflowDropOldestOnlyDropsOnAdd(t *testing.T) {
+	l := newBoundedLoop(1, OverflowDropOldest)
+	require.NoError(t, l.Post(namedOp("a")))
+
+	slot, err := l.Reserve(context.Background())
+	require.NoError(t, err)
+	slot.Release()
+	assert.Equal(t, QueueStats{Len: 1, Capacity: 1}, l.QueueStats(), "a released reservation drops nothing")
+
+	slot, err = l.Reserve(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, slot.Post(namedOp("b")))
+	assert.Equal(t, QueueStats{Len: 1, Capacity: 1, Dropped: 1}, l.QueueStats())
+	assert.Equal(t, []Operation{namedOp("b")}, pullAll(&l.mq))
+}
+
+func TestOverflowBlock(t *testing.T) {
+	l := newBoundedLoop(1, OverflowBlock)
+	slot, err := l.Reserve(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = l.Reserve(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	posted := make(chan error, 1)
+	go func() { posted <- l.Post(namedOp("b")) }()
+	select {
+	case <-posted:
+		t.Fatal("Post must wait for space in the queue")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	slot.Release()
+	require.NoError(t, <-posted)
+	assert.Equal(t, QueueStats{Len: 1, Capacity: 1}, l.QueueStats())
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	for _, p := range []OverflowPolicy{OverflowBlock, OverflowDropOldest, OverflowReject} {
+		got, err := ParseOverflowPolicy(p.String())
+		require.NoError(t, err)
+		assert.Equal(t, p, got)
+	}
+	_, err := ParseOverflowPolicy("ignore")
+	assert.Error(t, err)
+}
@@ -0,0 +1,153 @@
+package painter
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// Shape — об'єкт сцени, який можна намалювати та перемістити.
+type Shape interface {
+	Operation
+	// Translate повертає копію фігури, зміщену на (dx, dy). Сама фігура не змінюється,
+	// тому операції, вже передані у Loop, можна безпечно малювати паралельно зі змінами сцени.
+	Translate(dx, dy int) Shape
+	// Anchor повертає точку прив'язки фігури, яку переміщує анімація: центр для фігур з центром
+	// і лівий верхній кут для прямокутника.
+	Anchor() image.Point
+}
+
+// SceneObject пов'язує фігуру сцени з її стабільним ідентифікатором.
+type SceneObject struct {
+	ID    string
+	Shape Shape
+}
+
+// Scene зберігає фон та впорядкований список об'єктів з ідентифікаторами.
+// Об'єкти малюються у порядку додавання; заміна об'єкта з наявним ідентифікатором зберігає його місце.
+type Scene struct {
+	Background Operation
+	objects    []SceneObject
+	nextID     int
+}
+
+// NewID повертає ще не зайнятий ідентифікатор виду <prefix><номер>.
+func (s *Scene) NewID(prefix string) string {
+	for {
+		s.nextID++
+		id := fmt.Sprintf("%s%d", prefix, s.nextID)
+		if s.index(id) < 0 {
+			return id
+		}
+	}
+}
+
+// Put додає фігуру з ідентифікатором id у кінець сцени або замінює об'єкт, що вже має такий ідентифікатор.
+func (s *Scene) Put(id string, shape Shape) {
+	if i := s.index(id); i >= 0 {
+		s.objects[i].Shape = shape
+		return
+	}
+	s.objects = append(s.objects, SceneObject{ID: id, Shape: shape})
+}
+
+// Get повертає фігуру з ідентифікатором id.
+func (s *Scene) Get(id string) (Shape, bool) {
+	if i := s.index(id); i >= 0 {
+		return s.objects[i].Shape, true
+	}
+	return nil, false
+}
+
+// Move зміщує об'єкт id на (dx, dy). Якщо об'єкта з таким ідентифікатором немає, зміщує групу об'єктів,
+// ідентифікатори яких починаються з "<id>." (наприклад, фігури одного екземпляра макросу).
+// Повертає false, якщо не знайдено ні об'єкта, ні групи.
+func (s *Scene) Move(id string, dx, dy int) bool {
+	indices := s.group(id)
+	for _, i := range indices {
+		s.objects[i].Shape = s.objects[i].Shape.Translate(dx, dy)
+	}
+	return len(indices) > 0
+}
+
+// MoveFigures зміщує на (dx, dy) всі T-образні фігури сцени, як це робить MoveOp.
+func (s *Scene) MoveFigures(dx, dy int) {
+	for i, obj := range s.objects {
+		if _, ok := obj.Shape.(*FigureOp); ok {
+			s.objects[i].Shape = obj.Shape.Translate(dx, dy)
+		}
+	}
+}
+
+// Anchor повертає точку прив'язки об'єкта id або, якщо його немає, першого об'єкта групи "<id>." (див. Move).
+func (s *Scene) Anchor(id string) (image.Point, bool) {
+	indices := s.group(id)
+	if len(indices) == 0 {
+		return image.Point{}, false
+	}
+	return s.objects[indices[0]].Shape.Anchor(), true
+}
+
+// Delete видаляє об'єкт id або групу об'єктів "<id>." (див. Move) зі сцени.
+// Повертає false, якщо не знайдено ні об'єкта, ні групи.
+func (s *Scene) Delete(id string) bool {
+	indices := s.group(id)
+	for n, i := range indices {
+		// Після кожного видалення наступні об'єкти зсуваються на одну позицію.
+		i -= n
+		s.objects = append(s.objects[:i:i], s.objects[i+1:]...)
+	}
+	return len(indices) > 0
+}
+
+// Objects повертає копію списку об'єктів сцени у порядку малювання.
+func (s *Scene) Objects() []SceneObject {
+	return append([]SceneObject(nil), s.objects...)
+}
+
+// Clone повертає незалежну копію сцени. Фігури не копіюються, оскільки вони незмінні.
+func (s *Scene) Clone() Scene {
+	c := *s
+	c.objects = append([]SceneObject(nil), s.objects...)
+	return c
+}
+
+// Reset видаляє фон та всі об'єкти сцени.
+func (s *Scene) Reset() {
+	*s = Scene{}
+}
+
+// Operations формує список операцій для малювання сцени: спочатку фон, потім об'єкти у порядку додавання.
+func (s *Scene) Operations() []Operation {
+	res := make([]Operation, 0, len(s.objects)+1)
+	if s.Background != nil {
+		res = append(res, s.Background)
+	}
+	for _, obj := range s.objects {
+		res = append(res, obj.Shape)
+	}
+	return res
+}
+
+// group повертає у порядку зростання індекси об'єкта id або, якщо його немає, об'єктів групи "<id>.".
+func (s *Scene) group(id string) []int {
+	if i := s.index(id); i >= 0 {
+		return []int{i}
+	}
+	var res []int
+	for i, obj := range s.objects {
+		if strings.HasPrefix(obj.ID, id+".") {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+func (s *Scene) index(id string) int {
+	for i, obj := range s.objects {
+		if obj.ID == id {
+			return i
+		}
+	}
+	return -1
+}
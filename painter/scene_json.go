@@ -0,0 +1,166 @@
+package painter
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+)
+
+// SceneVersion — версія формату, у якому сцена зберігається як JSON.
+const SceneVersion = 1
+
+// sceneJSON — формат збереження сцени.
+type sceneJSON struct {
+	Version    int          `json:"version"`
+	Background string       `json:"background,omitempty"`
+	NextID     int          `json:"nextId,omitempty"`
+	Objects    []objectJSON `json:"objects"`
+}
+
+// objectJSON — формат збереження об'єкта сцени. Поля, які не стосуються типу об'єкта, пропускаються.
+type objectJSON struct {
+	ID    string  `json:"id"`
+	Type  string  `json:"type"`
+	X     int     `json:"x"`
+	Y     int     `json:"y"`
+	X2    int     `json:"x2,omitempty"`
+	Y2    int     `json:"y2,omitempty"`
+	R     int     `json:"r,omitempty"`
+	RX    int     `json:"rx,omitempty"`
+	RY    int     `json:"ry,omitempty"`
+	Start float64 `json:"start,omitempty"`
+	End   float64 `json:"end,omitempty"`
+	Width int     `json:"width,omitempty"`
+	Color string  `json:"color,omitempty"`
+	Blend string  `json:"blend,omitempty"`
+}
+
+// MarshalJSON кодує сцену у версійований JSON формат. Фон зберігається як колір заливки.
+func (s *Scene) MarshalJSON() ([]byte, error) {
+	out := sceneJSON{Version: SceneVersion, NextID: s.nextID, Objects: make([]objectJSON, 0, len(s.objects))}
+
+	switch bg := s.Background.(type) {
+	case nil:
+	case *FillOp:
+		out.Background = hexColor(colorOr(bg.Color, color.Black))
+	case OperationFunc:
+		// Reset — єдина функція, яку парсер використовує як фон; вона заливає текстуру чорним.
+		out.Background = hexColor(color.Black)
+	default:
+		return nil, fmt.Errorf("cannot save background of type %T", s.Background)
+	}
+
+	for _, obj := range s.objects {
+		o := objectJSON{ID: obj.ID}
+		switch sh := obj.Shape.(type) {
+		case *FigureOp:
+			o.Type, o.X, o.Y = "figure", sh.X, sh.Y
+			o.Color, o.Blend = hexColor(sh.Color), blendName(sh.Blend)
+		case *BgRectOp:
+			o.Type, o.X, o.Y, o.X2, o.Y2 = "bgrect", sh.X1, sh.Y1, sh.X2, sh.Y2
+			o.Color, o.Blend = hexColor(sh.Color), blendName(sh.Blend)
+		case *CircleOp:
+			o.Type, o.X, o.Y, o.R, o.Width = "circle", sh.X, sh.Y, sh.R, sh.Width
+			o.Color, o.Blend = hexColor(sh.Color), blendName(sh.Blend)
+		case *EllipseOp:
+			o.Type, o.X, o.Y, o.RX, o.RY, o.Width = "ellipse", sh.X, sh.Y, sh.RX, sh.RY, sh.Width
+			o.Color, o.Blend = hexColor(sh.Color), blendName(sh.Blend)
+		case *ArcOp:
+			o.Type, o.X, o.Y, o.R, o.Start, o.End, o.Width = "arc", sh.X, sh.Y, sh.R, sh.Start, sh.End, sh.Width
+			o.Color, o.Blend = hexColor(sh.Color), blendName(sh.Blend)
+		default:
+			return nil, fmt.Errorf("cannot save object %s of type %T", obj.ID, obj.Shape)
+		}
+		out.Objects = append(out.Objects, o)
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON відновлює сцену, збережену MarshalJSON.
+func (s *Scene) UnmarshalJSON(data []byte) error {
+	var in sceneJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	if in.Version < 1 || in.Version > SceneVersion {
+		return fmt.Errorf("unsupported scene version: %d", in.Version)
+	}
+
+	scene := Scene{nextID: in.NextID}
+	if in.Background != "" {
+		c, err := parseHexColor(in.Background)
+		if err != nil {
+			return err
+		}
+		scene.Background = &FillOp{Color: c}
+	}
+
+	for _, o := range in.Objects {
+		if o.ID == "" || scene.index(o.ID) >= 0 {
+			return fmt.Errorf("missing or duplicate object id: %q", o.ID)
+		}
+		var c color.Color
+		if o.Color != "" {
+			var err error
+			if c, err = parseHexColor(o.Color); err != nil {
+				return err
+			}
+		}
+		mode := BlendOver
+		if o.Blend != "" {
+			var err error
+			if mode, err = ParseBlendMode(o.Blend); err != nil {
+				return err
+			}
+		}
+
+		var shape Shape
+		switch o.Type {
+		case "figure":
+			shape = &FigureOp{X: o.X, Y: o.Y, Color: c, Blend: mode}
+		case "bgrect":
+			shape = &BgRectOp{X1: o.X, Y1: o.Y, X2: o.X2, Y2: o.Y2, Color: c, Blend: mode}
+		case "circle":
+			shape = &CircleOp{X: o.X, Y: o.Y, R: o.R, Width: o.Width, Color: c, Blend: mode}
+		case "ellipse":
+			shape = &EllipseOp{X: o.X, Y: o.Y, RX: o.RX, RY: o.RY, Width: o.Width, Color: c, Blend: mode}
+		case "arc":
+			shape = &ArcOp{X: o.X, Y: o.Y, R: o.R, Start: o.Start, End: o.End, Width: o.Width, Color: c, Blend: mode}
+		default:
+			return fmt.Errorf("unknown object type: %q", o.Type)
+		}
+		scene.objects = append(scene.objects, SceneObject{ID: o.ID, Shape: shape})
+	}
+
+	*s = scene
+	return nil
+}
+
+// hexColor повертає колір у форматі #rrggbbaa або порожній рядок, якщо колір не задано.
+func hexColor(c color.Color) string {
+	if c == nil {
+		return ""
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x%02x", n.R, n.G, n.B, n.A)
+}
+
+func parseHexColor(s string) (color.Color, error) {
+	var n color.NRGBA
+	if len(s) != 9 {
+		return nil, fmt.Errorf("invalid color: %q", s)
+	}
+	if _, err := fmt.Sscanf(s, "#%02x%02x%02x%02x", &n.R, &n.G, &n.B, &n.A); err != nil {
+		return nil, fmt.Errorf("invalid color: %q", s)
+	}
+	return n, nil
+}
+
+// blendName повертає назву режиму змішування або порожній рядок для режиму за замовчуванням.
+func blendName(m BlendMode) string {
+	if m == BlendOver {
+		return ""
+	}
+	return m.String()
+}
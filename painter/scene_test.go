@@ -0,0 +1,111 @@
+package painter
+
+import (
+	"encoding/json"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScenePutReplacesInPlace(t *testing.T) {
+	var scene Scene
+	scene.Put("a", &FigureOp{X: 1})
+	scene.Put("b", &FigureOp{X: 2})
+	scene.Put("a", &FigureOp{X: 3})
+
+	objects := scene.Objects()
+	assert.Equal(t, []string{"a", "b"}, []string{objects[0].ID, objects[1].ID})
+	assert.Equal(t, &FigureOp{X: 3}, objects[0].Shape)
+}
+
+func TestSceneMoveKeepsOldSnapshots(t *testing.T) {
+	var scene Scene
+	scene.Put("a", &FigureOp{X: 10, Y: 10})
+	before := scene.Operations()
+
+	assert.True(t, scene.Move("a", 5, -5))
+	assert.False(t, scene.Move("missing", 1, 1))
+
+	shape, _ := scene.Get("a")
+	assert.Equal(t, &FigureOp{X: 15, Y: 5}, shape)
+	assert.Equal(t, &FigureOp{X: 10, Y: 10}, before[0], "operations already handed to the loop must not change")
+}
+
+func TestSceneMoveFigures(t *testing.T) {
+	var scene Scene
+	scene.Put("f", &FigureOp{X: 10, Y: 10})
+	scene.Put("c", &CircleOp{X: 10, Y: 10, R: 5})
+
+	scene.MoveFigures(1, 2)
+
+	f, _ := scene.Get("f")
+	c, _ := scene.Get("c")
+	assert.Equal(t, &FigureOp{X: 11, Y: 12}, f)
+	assert.Equal(t, &CircleOp{X: 10, Y: 10, R: 5}, c)
+}
+
+func TestSceneDeleteAndOperations(t *testing.T) {
+	scene := Scene{Background: &FillOp{}}
+	scene.Put("a", &FigureOp{})
+	scene.Put("b", &BgRectOp{})
+
+	assert.True(t, scene.Delete("a"))
+	assert.False(t, scene.Delete("a"))
+	assert.Equal(t, []Operation{&FillOp{}, &BgRectOp{}}, scene.Operations())
+}
+
+func TestSceneNewID(t *testing.T) {
+	var scene Scene
+	scene.Put("figure1", &FigureOp{})
+
+	assert.Equal(t, "figure2", scene.NewID("figure"))
+	assert.Equal(t, "circle3", scene.NewID("circle"))
+}
+
+func TestSceneJSONRoundTrip(t *testing.T) {
+	scene := Scene{Background: &FillOp{Color: color.RGBA{R: 0xff, A: 0xff}}}
+	scene.Put("f", &FigureOp{X: 10, Y: 20, Color: color.NRGBA{B: 0xff, A: 0x80}, Blend: BlendMultiply})
+	scene.Put("r", &BgRectOp{X1: 1, Y1: 2, X2: 3, Y2: 4})
+	scene.Put("c", &CircleOp{X: 5, Y: 6, R: 7, Width: 2, Color: color.NRGBA{G: 0xff, A: 0xff}})
+	scene.Put("e", &EllipseOp{X: 5, Y: 6, RX: 7, RY: 8, Color: color.NRGBA{R: 1, G: 2, B: 3, A: 4}, Blend: BlendXor})
+	scene.Put("a", &ArcOp{X: 5, Y: 6, R: 7, Start: 10, End: 270.5, Width: 1})
+	scene.NewID("figure")
+
+	data, err := json.Marshal(&scene)
+	require.NoError(t, err)
+
+	var loaded Scene
+	require.NoError(t, json.Unmarshal(data, &loaded))
+	assert.Equal(t, &FillOp{Color: color.NRGBA{R: 0xff, A: 0xff}}, loaded.Background)
+	assert.Equal(t, scene.Objects(), loaded.Objects())
+	assert.Equal(t, "figure2", loaded.NewID("figure"), "id counter is preserved")
+}
+
+func TestSceneJSONRejectsUnknownVersion(t *testing.T) {
+	var scene Scene
+	assert.Error(t, scene.UnmarshalJSON([]byte(`{"version": 2, "objects": []}`)))
+	assert.Error(t, scene.UnmarshalJSON([]byte(`{"objects": []}`)))
+	assert.Error(t, scene.UnmarshalJSON([]byte(`{"version": 1, "objects": [{"id": "x", "type": "star"}]}`)))
+}
+
+func TestSceneGroups(t *testing.T) {
+	var scene Scene
+	scene.Put("logo.a", &FigureOp{X: 1})
+	scene.Put("other", &FigureOp{X: 1})
+	scene.Put("logo.b", &FigureOp{X: 2})
+	scene.Put("logo2.a", &FigureOp{X: 3})
+
+	assert.True(t, scene.Move("logo", 10, 0))
+	a, _ := scene.Get("logo.a")
+	b, _ := scene.Get("logo.b")
+	c, _ := scene.Get("logo2.a")
+	assert.Equal(t, &FigureOp{X: 11}, a)
+	assert.Equal(t, &FigureOp{X: 12}, b)
+	assert.Equal(t, &FigureOp{X: 3}, c, "only objects with the exact group prefix are moved")
+
+	assert.True(t, scene.Delete("logo"))
+	assert.False(t, scene.Delete("logo"))
+	assert.Equal(t, []Operation{&FigureOp{X: 1}, &FigureOp{X: 3}}, scene.Operations())
+}
@@ -0,0 +1,160 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// CircleOp малює коло кольору Color (за замовчуванням FigureColor) з центром (X, Y) та радіусом R
+// у режимі змішування Blend.
+// Якщо Width більше нуля, малюється лише контур такої товщини, інакше коло заповнюється повністю.
+type CircleOp struct {
+	X     int
+	Y     int
+	R     int
+	Width int
+	Color color.Color
+	Blend BlendMode
+}
+
+// EllipseOp малює еліпс з центром (X, Y) та півосями RX і RY. Width, Color та Blend мають той самий зміст, що й у CircleOp.
+type EllipseOp struct {
+	X     int
+	Y     int
+	RX    int
+	RY    int
+	Width int
+	Color color.Color
+	Blend BlendMode
+}
+
+// ArcOp малює дугу кола кольору Color у режимі змішування Blend з центром (X, Y) та радіусом R від кута Start до кута End.
+// Кути задаються у градусах проти годинникової стрілки від напрямку праворуч.
+// Якщо Width більше нуля, малюється дуга такої товщини, інакше — заповнений сектор.
+type ArcOp struct {
+	X     int
+	Y     int
+	R     int
+	Start float64
+	End   float64
+	Width int
+	Color color.Color
+	Blend BlendMode
+}
+
+// Do виконує операцію на об'єкті CircleOp, малюючи коло на текстурі.
+func (op *CircleOp) Do(t screen.Texture) bool {
+	ellipse(t, op.X, op.Y, op.R, op.R, op.Width, colorOr(op.Color, FigureColor), op.Blend)
+	return false
+}
+
+// Do виконує операцію на об'єкті EllipseOp, малюючи еліпс на текстурі.
+func (op *EllipseOp) Do(t screen.Texture) bool {
+	ellipse(t, op.X, op.Y, op.RX, op.RY, op.Width, colorOr(op.Color, FigureColor), op.Blend)
+	return false
+}
+
+// Do виконує операцію на об'єкті ArcOp, малюючи дугу або сектор на текстурі.
+func (op *ArcOp) Do(t screen.Texture) bool {
+	sweep := op.End - op.Start
+	if sweep < 0 {
+		sweep = math.Mod(sweep, 360) + 360
+	}
+	inner := float64(op.R - op.Width)
+	r := float64(op.R)
+
+	scanFill(t, image.Rect(op.X-op.R, op.Y-op.R, op.X+op.R, op.Y+op.R), colorOr(op.Color, FigureColor), op.Blend, func(x, y float64) bool {
+		dx, dy := x-float64(op.X), y-float64(op.Y)
+		dist := math.Hypot(dx, dy)
+		if dist > r || (op.Width > 0 && dist <= inner) {
+			return false
+		}
+		if sweep >= 360 {
+			return true
+		}
+		angle := math.Atan2(-dy, dx) * 180 / math.Pi
+		return math.Mod(math.Mod(angle-op.Start, 360)+360, 360) <= sweep
+	})
+
+	return false
+}
+
+// Translate повертає копію кола, зміщену на (dx, dy).
+func (op *CircleOp) Translate(dx, dy int) Shape {
+	moved := *op
+	moved.X, moved.Y = op.X+dx, op.Y+dy
+	return &moved
+}
+
+// Translate повертає копію еліпса, зміщену на (dx, dy).
+func (op *EllipseOp) Translate(dx, dy int) Shape {
+	moved := *op
+	moved.X, moved.Y = op.X+dx, op.Y+dy
+	return &moved
+}
+
+// Translate повертає копію дуги, зміщену на (dx, dy).
+func (op *ArcOp) Translate(dx, dy int) Shape {
+	moved := *op
+	moved.X, moved.Y = op.X+dx, op.Y+dy
+	return &moved
+}
+
+// Anchor повертає центр кола.
+func (op *CircleOp) Anchor() image.Point {
+	return image.Pt(op.X, op.Y)
+}
+
+// Anchor повертає центр еліпса.
+func (op *EllipseOp) Anchor() image.Point {
+	return image.Pt(op.X, op.Y)
+}
+
+// Anchor повертає центр дуги.
+func (op *ArcOp) Anchor() image.Point {
+	return image.Pt(op.X, op.Y)
+}
+
+// ellipse малює заповнений еліпс або його контур товщиною width.
+func ellipse(t screen.Texture, cx, cy, rx, ry, width int, c color.Color, mode BlendMode) {
+	if rx <= 0 || ry <= 0 {
+		return
+	}
+	inRX, inRY := float64(rx-width), float64(ry-width)
+	hollow := width > 0 && inRX > 0 && inRY > 0
+
+	scanFill(t, image.Rect(cx-rx, cy-ry, cx+rx, cy+ry), c, mode, func(x, y float64) bool {
+		dx, dy := x-float64(cx), y-float64(cy)
+		if !insideEllipse(dx, dy, float64(rx), float64(ry)) {
+			return false
+		}
+		return !hollow || !insideEllipse(dx, dy, inRX, inRY)
+	})
+}
+
+func insideEllipse(dx, dy, rx, ry float64) bool {
+	return (dx*dx)/(rx*rx)+(dy*dy)/(ry*ry) <= 1
+}
+
+// scanFill зафарбовує у межах r всі пікселі, центр яких задовольняє inside, у режимі змішування mode.
+// Кожен рядок обходиться зліва направо, і кожен неперервний відрізок пікселів заливається одним викликом Fill.
+func scanFill(t screen.Texture, r image.Rectangle, c color.Color, mode BlendMode, inside func(x, y float64) bool) {
+	r = r.Intersect(t.Bounds())
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		start := -1
+		for x := r.Min.X; x <= r.Max.X; x++ {
+			in := x < r.Max.X && inside(float64(x)+0.5, float64(y)+0.5)
+			switch {
+			case in && start < 0:
+				start = x
+			case !in && start >= 0:
+				fill(t, image.Rect(start, y, x, y+1), c, mode)
+				start = -1
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package painter
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func drawShape(op Operation) *image.RGBA {
+	tx := NewImageTexture(image.Pt(100, 100))
+	(&FillOp{Color: white}).Do(tx)
+	op.Do(tx)
+	return tx.RGBA()
+}
+
+func TestCircleFilled(t *testing.T) {
+	img := drawShape(&CircleOp{X: 50, Y: 50, R: 20})
+
+	assert.Equal(t, blue, img.RGBAAt(50, 50))
+	assert.Equal(t, blue, img.RGBAAt(69, 50))
+	assert.Equal(t, white, img.RGBAAt(71, 50))
+	assert.Equal(t, white, img.RGBAAt(65, 65), "corner of the bounding box lies outside the circle")
+}
+
+func TestCircleOutline(t *testing.T) {
+	img := drawShape(&CircleOp{X: 50, Y: 50, R: 20, Width: 4})
+
+	assert.Equal(t, white, img.RGBAAt(50, 50))
+	assert.Equal(t, blue, img.RGBAAt(68, 50))
+	assert.Equal(t, white, img.RGBAAt(64, 50))
+}
+
+func TestEllipse(t *testing.T) {
+	img := drawShape(&EllipseOp{X: 50, Y: 50, RX: 40, RY: 10})
+
+	assert.Equal(t, blue, img.RGBAAt(85, 50))
+	assert.Equal(t, white, img.RGBAAt(50, 62))
+	assert.Equal(t, blue, img.RGBAAt(50, 58))
+}
+
+func TestArc(t *testing.T) {
+	img := drawShape(&ArcOp{X: 50, Y: 50, R: 30, Start: 0, End: 90})
+
+	assert.Equal(t, blue, img.RGBAAt(60, 40), "upper right quarter")
+	assert.Equal(t, white, img.RGBAAt(40, 40), "upper left quarter")
+	assert.Equal(t, white, img.RGBAAt(60, 60), "lower right quarter")
+}
+
+func TestArcWrapsAround(t *testing.T) {
+	img := drawShape(&ArcOp{X: 50, Y: 50, R: 30, Start: 270, End: 90, Width: 10})
+
+	assert.Equal(t, blue, img.RGBAAt(75, 50), "right side is inside the 270..90 sweep")
+	assert.Equal(t, white, img.RGBAAt(25, 50), "left side is outside the sweep")
+	assert.Equal(t, white, img.RGBAAt(55, 50), "inner part of the arc stays empty")
+}
@@ -0,0 +1,95 @@
+package painter
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// HeadlessScreen реалізує screen.Screen без графічного вікна: текстури та буфери зберігаються у пам'яті як image.RGBA.
+// Дозволяє запускати Loop у тестах та на CI, де немає дисплея.
+type HeadlessScreen struct{}
+
+// NewBuffer створює буфер у пам'яті заданого розміру.
+func (HeadlessScreen) NewBuffer(size image.Point) (screen.Buffer, error) {
+	return &ImageBuffer{img: image.NewRGBA(image.Rectangle{Max: size})}, nil
+}
+
+// NewTexture створює програмну текстуру заданого розміру.
+func (HeadlessScreen) NewTexture(size image.Point) (screen.Texture, error) {
+	return NewImageTexture(size), nil
+}
+
+// NewWindow завжди повертає помилку, оскільки HeadlessScreen не має дисплея.
+func (HeadlessScreen) NewWindow(*screen.NewWindowOptions) (screen.Window, error) {
+	return nil, errors.New("headless screen cannot create windows")
+}
+
+// ImageBuffer реалізує screen.Buffer поверх image.RGBA.
+type ImageBuffer struct {
+	img *image.RGBA
+}
+
+func (b *ImageBuffer) Release()                {}
+func (b *ImageBuffer) Size() image.Point       { return b.img.Rect.Size() }
+func (b *ImageBuffer) Bounds() image.Rectangle { return b.img.Rect }
+func (b *ImageBuffer) RGBA() *image.RGBA       { return b.img }
+
+// ImageTexture реалізує screen.Texture поверх image.RGBA, тому намальовані пікселі можна прочитати або експортувати.
+type ImageTexture struct {
+	img *image.RGBA
+}
+
+// NewImageTexture створює прозору програмну текстуру заданого розміру.
+func NewImageTexture(size image.Point) *ImageTexture {
+	return &ImageTexture{img: image.NewRGBA(image.Rectangle{Max: size})}
+}
+
+func (t *ImageTexture) Release()                {}
+func (t *ImageTexture) Size() image.Point       { return t.img.Rect.Size() }
+func (t *ImageTexture) Bounds() image.Rectangle { return t.img.Rect }
+
+// RGBA повертає зображення, у яке малює текстура. Зміни цього зображення одразу видно у текстурі.
+func (t *ImageTexture) RGBA() *image.RGBA { return t.img }
+
+// Upload копіює частину буфера src у текстуру так, що sr.Min потрапляє у точку dp.
+func (t *ImageTexture) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {
+	dr := image.Rectangle{Min: dp, Max: dp.Add(sr.Size())}
+	draw.Draw(t.img, dr, src.RGBA(), sr.Min, draw.Src)
+}
+
+// Fill зафарбовує прямокутник dr кольором src з використанням оператора op.
+func (t *ImageTexture) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
+	draw.Draw(t.img, dr, image.NewUniform(src), image.Point{}, op)
+}
+
+// Snapshot повертає копію поточного вмісту текстури.
+func (t *ImageTexture) Snapshot() *image.RGBA {
+	img := image.NewRGBA(t.img.Rect)
+	copy(img.Pix, t.img.Pix)
+	return img
+}
+
+// EncodePNG записує поточний вміст текстури у w у форматі PNG.
+func (t *ImageTexture) EncodePNG(w io.Writer) error {
+	return png.Encode(w, t.img)
+}
+
+// SavePNG зберігає поточний вміст текстури у файл path у форматі PNG.
+func (t *ImageTexture) SavePNG(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := t.EncodePNG(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,110 @@
+package painter
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/shiny/screen"
+)
+
+// frameReceiver зберігає копії кадрів, які Loop передає у Receiver.
+type frameReceiver struct {
+	frames chan *image.RGBA
+}
+
+func (r *frameReceiver) Update(t screen.Texture) {
+	r.frames <- t.(*ImageTexture).Snapshot()
+}
+
+// renderHeadless запускає Loop без вікна, виконує операції та повертає отриманий кадр.
+func renderHeadless(t *testing.T, ops ...Operation) *image.RGBA {
+	t.Helper()
+
+	receiver := &frameReceiver{frames: make(chan *image.RGBA, 1)}
+	loop := &Loop{Receiver: receiver}
+	loop.Start(context.Background(), HeadlessScreen{})
+	defer loop.StopAndWait()
+	loop.Post(OperationList(append(ops, UpdateOp)))
+
+	select {
+	case frame := <-receiver.frames:
+		return frame
+	case <-time.After(time.Second):
+		t.Fatal("frame was not rendered")
+		return nil
+	}
+}
+
+var (
+	white = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	black = color.RGBA{A: 255}
+	blue  = color.RGBA{B: 255, A: 255}
+)
+
+func TestImageTextureFill(t *testing.T) {
+	tx := NewImageTexture(image.Pt(10, 10))
+	tx.Fill(image.Rect(2, 2, 5, 5), color.RGBA{R: 255, A: 255}, screen.Src)
+
+	assert.Equal(t, image.Rect(0, 0, 10, 10), tx.Bounds())
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, tx.RGBA().RGBAAt(3, 3))
+	assert.Equal(t, color.RGBA{}, tx.RGBA().RGBAAt(6, 6))
+}
+
+func TestImageTextureUpload(t *testing.T) {
+	buf, err := HeadlessScreen{}.NewBuffer(image.Pt(4, 4))
+	require.NoError(t, err)
+	buf.RGBA().SetRGBA(1, 1, blue)
+
+	tx := NewImageTexture(image.Pt(10, 10))
+	tx.Upload(image.Pt(5, 5), buf, buf.Bounds())
+
+	assert.Equal(t, blue, tx.RGBA().RGBAAt(6, 6))
+	assert.Equal(t, color.RGBA{}, tx.RGBA().RGBAAt(1, 1))
+}
+
+func TestHeadlessBgRect(t *testing.T) {
+	frame := renderHeadless(t, &FillOp{Color: white}, &BgRectOp{X1: 10, Y1: 10, X2: 50, Y2: 50})
+
+	assert.Equal(t, black, frame.RGBAAt(30, 30))
+	assert.Equal(t, white, frame.RGBAAt(60, 60))
+}
+
+func TestHeadlessFigure(t *testing.T) {
+	frame := renderHeadless(t, &FillOp{Color: white}, &FigureOp{X: 200, Y: 200})
+
+	assert.Equal(t, blue, frame.RGBAAt(150, 180), "top bar of the T-shape")
+	assert.Equal(t, blue, frame.RGBAAt(200, 220), "stem of the T-shape")
+	assert.Equal(t, white, frame.RGBAAt(150, 220), "empty space beside the stem")
+}
+
+func TestHeadlessMove(t *testing.T) {
+	figure := &FigureOp{X: 200, Y: 200}
+	frame := renderHeadless(t,
+		&FillOp{Color: white},
+		&MoveOp{X: 100, Y: 100, Figures: []*FigureOp{figure}},
+		figure,
+	)
+
+	assert.Equal(t, blue, frame.RGBAAt(300, 320))
+	assert.Equal(t, white, frame.RGBAAt(200, 220))
+}
+
+func TestImageTextureEncodePNG(t *testing.T) {
+	tx := NewImageTexture(image.Pt(8, 8))
+	tx.Fill(tx.Bounds(), blue, screen.Src)
+
+	var buf bytes.Buffer
+	require.NoError(t, tx.EncodePNG(&buf))
+
+	img, err := png.Decode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, tx.Bounds(), img.Bounds())
+	assert.Equal(t, color.RGBAModel.Convert(blue), color.RGBAModel.Convert(img.At(4, 4)))
+}
@@ -9,9 +9,9 @@ import (
 
 func main() {
 	data := []byte(
-		`white
+		`fill white
 bgrect 0.15 0.15 0.35 0.35
-green
+fill lime
 figure 0.25 0.25
 update`)
 	_, err := http.Post("http://localhost:17000/", "application/text", bytes.NewBuffer(data))
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Те саме, що й scripts/move, але через одне WebSocket з'єднання:
+// наступний кадр надсилається лише після підтвердження, що попередній вже відображено.
+func main() {
+	conn, _, err := websocket.DefaultDialer.Dial("ws://localhost:17000/ws", nil)
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+
+	script := `fill white
+bgrect 0.15 0.15 0.35 0.35
+fill lime
+figure id=t 0.25 0.25
+update`
+	for {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(script)); err != nil {
+			panic(err)
+		}
+
+		var msg struct {
+			Type  string `json:"type"`
+			Error string `json:"error"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			panic(err)
+		}
+		if msg.Type == "error" {
+			fmt.Println("Error occurred:", msg.Error)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		script = "move t 0.005 0.005\nupdate"
+	}
+}
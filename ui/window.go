@@ -42,8 +42,12 @@ func (pw *Visualizer) Main() {
 	driver.Main(pw.run)
 }
 
+// Update передає текстуру для відображення. Після закриття вікна текстура ігнорується, щоб не блокувати відправника.
 func (pw *Visualizer) Update(t screen.Texture) {
-	pw.tx <- t
+	select {
+	case pw.tx <- t:
+	case <-pw.done:
+	}
 }
 
 func (pw *Visualizer) run(s screen.Screen) {